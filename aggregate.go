@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// aggregateResult is the --aggregate rollup over every CIDR evaluated in a batch.
+// Overlapping inputs are merged before counting, so each address is counted once;
+// OverlapCount reports how many input addresses were duplicates.
+type aggregateResult struct {
+	// Inputs is the number of CIDRs that were fully evaluated; refused, sampled and
+	// reserved CIDRs are not included.
+	Inputs int `json:"inputs"`
+	// Prefixes is the de-overlapped union of the inputs.
+	Prefixes     []string `json:"prefixes"`
+	Total        *big.Int `json:"total"`
+	OverlapCount *big.Int `json:"overlap_count"`
+	OwnedCount   int      `json:"owned_count"`
+	// LabelSets counts distinct addresses by the comma-joined set of labels they matched.
+	LabelSets map[string]int `json:"label_sets"`
+	// MatchedPrefixes lists the distinct published prefixes overlapping any input.
+	MatchedPrefixes []string `json:"matched_prefixes"`
+}
+
+// aggregateInputs merges the CIDRs recorded for --aggregate and evaluates the union.
+func (c *checker) aggregateInputs() aggregateResult {
+	return c.aggregatePrefixes(c.aggregated)
+}
+
+// aggregatePrefixes merges inputs and evaluates the union.
+func (c *checker) aggregatePrefixes(inputs []netip.Prefix) aggregateResult {
+	res := aggregateResult{
+		Inputs:          len(inputs),
+		Prefixes:        []string{},
+		Total:           new(big.Int),
+		OverlapCount:    new(big.Int),
+		LabelSets:       map[string]int{},
+		MatchedPrefixes: []string{},
+	}
+	for _, p := range inputs {
+		res.OverlapCount.Add(res.OverlapCount, githubmeta.PrefixSize(p))
+	}
+
+	var matched []netip.Prefix
+	seen := map[netip.Prefix]bool{}
+	for _, p := range githubmeta.Coalesce(inputs) {
+		res.Prefixes = append(res.Prefixes, p.String())
+		// The union is no larger than the inputs, each of which was under the limit.
+		summary, err := c.meta.EvaluatePrefix(p, math.MaxInt)
+		if err != nil {
+			continue
+		}
+		res.Total.Add(res.Total, summary.Total)
+		res.OwnedCount += summary.Owned
+		for set, n := range summary.LabelSets {
+			res.LabelSets[set] += n
+		}
+		for _, entry := range c.meta.EntriesOverlapping(p) {
+			if !seen[entry.Prefix] {
+				seen[entry.Prefix] = true
+				matched = append(matched, entry.Prefix)
+			}
+		}
+	}
+	res.OverlapCount.Sub(res.OverlapCount, res.Total)
+	sort.Slice(matched, func(i, j int) bool {
+		if cmp := matched[i].Addr().Compare(matched[j].Addr()); cmp != 0 {
+			return cmp < 0
+		}
+		return matched[i].Bits() < matched[j].Bits()
+	})
+	for _, p := range matched {
+		res.MatchedPrefixes = append(res.MatchedPrefixes, p.String())
+	}
+	return res
+}
+
+// emitAggregate prints the --aggregate rollup after the per-input results.
+func (c *checker) emitAggregate() {
+	res := c.aggregateInputs()
+	wrapped := struct {
+		Aggregate aggregateResult `json:"aggregate"`
+	}{res}
+	if c.jsonOut != nil {
+		c.emitJSON(c.jsonOut, "aggregate", wrapped)
+	}
+	if c.format == "json" {
+		c.emitJSON(c.out, "aggregate", wrapped)
+		return
+	}
+
+	if !c.groupByFamily {
+		c.printAggregate("Aggregate", res)
+		return
+	}
+	// --group-by-family splits the text rollup into one per family.
+	for _, family := range []struct {
+		name string
+		in   func(netip.Addr) bool
+	}{{"IPv4", netip.Addr.Is4}, {"IPv6", netip.Addr.Is6}} {
+		var inputs []netip.Prefix
+		for _, p := range c.aggregated {
+			if family.in(p.Addr()) {
+				inputs = append(inputs, p)
+			}
+		}
+		if len(inputs) > 0 {
+			c.printAggregate(family.name+" aggregate", c.aggregatePrefixes(inputs))
+		}
+	}
+}
+
+// printAggregate writes res as text under title.
+func (c *checker) printAggregate(title string, res aggregateResult) {
+	fmt.Fprintf(c.out, "%s: %d of %s addresses owned by GitHub across %d CIDRs", title, res.OwnedCount, res.Total, res.Inputs)
+	if res.OverlapCount.Sign() > 0 {
+		fmt.Fprintf(c.out, " (%s overlapping addresses counted once)", res.OverlapCount)
+	}
+	fmt.Fprintln(c.out)
+	for _, set := range sortedLabelSets(res.LabelSets) {
+		fmt.Fprintf(c.out, "  %s: %d addresses\n", set, res.LabelSets[set])
+	}
+	if len(res.MatchedPrefixes) > 0 {
+		fmt.Fprintf(c.out, "  matched prefixes: %s\n", strings.Join(res.MatchedPrefixes, ", "))
+	} else if c.disclaimer != "" {
+		fmt.Fprintf(c.out, "  (%s)\n", c.disclaimer)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// annotateNone is the --annotate column of rows whose address GitHub does not own or
+// that have no address in the column.
+const annotateNone = "-"
+
+// annotate implements --annotate: it copies every line of the --input file (stdin
+// when path is "" or "-") to stdout with one more delim-separated column holding the
+// labels of the address in column (counting from 1). Lines are otherwise passed
+// through unchanged, blank ones included.
+func (a *app) annotate(meta *githubmeta.MetaData, path string, column int, delim string) error {
+	var r io.Reader = a.stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	// The labels are joined with commas unless that would add columns.
+	sep := ","
+	if delim == "," {
+		sep = ";"
+	}
+
+	w := bufio.NewWriter(a.stdout)
+	scanner := newInputScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			fmt.Fprintln(w)
+			continue
+		}
+		value := annotateNone
+		if fields := strings.Split(line, delim); column <= len(fields) {
+			if addr, ok := annotateAddr(fields[column-1]); ok {
+				if labels := meta.Lookup(addr); len(labels) > 0 {
+					value = strings.Join(labels, sep)
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s%s%s\n", line, delim, value)
+	}
+	if err := scanner.Err(); err != nil {
+		w.Flush()
+		return fmt.Errorf("read input: %w", inputLineError(err))
+	}
+	return w.Flush()
+}
+
+// annotateAddr parses an --annotate column holding an address, optionally with a
+// port as in "192.30.252.44:443" or "[2001:db8::1]:443".
+func annotateAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr, true
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr(), true
+	}
+	return netip.Addr{}, false
+}
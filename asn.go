@@ -0,0 +1,31 @@
+package main
+
+import "net/netip"
+
+// asnPrefix is a prefix announced by one of GitHub's autonomous systems.
+type asnPrefix struct {
+	asn    string
+	prefix netip.Prefix
+}
+
+// githubASNPrefixes are the prefixes originated by GitHub's AS36459 at the time of
+// writing. They back --asn, which flags addresses missing from the meta data that
+// GitHub nonetheless routes; unlike the meta data this list is not fetched.
+var githubASNPrefixes = []asnPrefix{
+	{"AS36459", netip.MustParsePrefix("140.82.112.0/20")},
+	{"AS36459", netip.MustParsePrefix("143.55.64.0/20")},
+	{"AS36459", netip.MustParsePrefix("185.199.108.0/22")},
+	{"AS36459", netip.MustParsePrefix("192.30.252.0/22")},
+	{"AS36459", netip.MustParsePrefix("2606:50c0::/32")},
+	{"AS36459", netip.MustParsePrefix("2a0a:a440::/29")},
+}
+
+// lookupASN returns the ASN announcing addr, or "" if none of prefixes contains it.
+func lookupASN(prefixes []asnPrefix, addr netip.Addr) string {
+	for _, p := range prefixes {
+		if p.prefix.Contains(addr) {
+			return p.asn
+		}
+	}
+	return ""
+}
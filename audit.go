@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// maxAllowlistBytes caps an --audit-allowlist file or response; a list of CIDRs
+// anywhere near this size is almost certainly the wrong document.
+const maxAllowlistBytes = 4 << 20
+
+// auditAllowlist implements --audit-allowlist: it prints, per label, the parts of
+// GitHub's ranges the allowlist at source (a file or an http(s) URL) does not cover.
+// It returns 1 when anything is uncovered, so CI jobs fail until the list is updated.
+func (a *app) auditAllowlist(ctx context.Context, meta *githubmeta.MetaData, src sourceOptions, source string) int {
+	allowed, err := a.readAllowlist(ctx, src, source)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	uncovered := 0
+	for _, label := range meta.Labels() {
+		for _, p := range githubmeta.Subtract(meta.LabelPrefixes(label), allowed) {
+			fmt.Fprintf(a.stdout, "%s: %s\n", label, p)
+			uncovered++
+		}
+	}
+	if uncovered > 0 {
+		fmt.Fprintf(a.stdout, "%d GitHub blocks are not covered by %s\n", uncovered, source)
+		return 1
+	}
+	fmt.Fprintf(a.stdout, "all GitHub ranges are covered by %s\n", source)
+	return 0
+}
+
+// readAllowlist parses the CIDRs and addresses of the allowlist at source, a file
+// path ("-" reads stdin) or an http(s) URL. Entries are separated like --input
+// lines, and lines starting with # are comments.
+func (a *app) readAllowlist(ctx context.Context, src sourceOptions, source string) ([]netip.Prefix, error) {
+	var r io.Reader
+	switch {
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		raw, err := a.fetchAllowlist(ctx, src, source)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(raw)
+	case source == "-":
+		r = a.stdin
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("open allowlist: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Read one byte past the cap so an oversized list is refused rather than truncated.
+	limited := &io.LimitedReader{R: r, N: maxAllowlistBytes + 1}
+	var prefixes []netip.Prefix
+	var bad []string
+	err := readInputs(limited, func(s string) {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p)
+		} else if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		} else {
+			bad = append(bad, s)
+		}
+	})
+	switch {
+	case limited.N == 0:
+		return nil, fmt.Errorf("allowlist %s exceeds %d bytes", source, maxAllowlistBytes)
+	case err != nil:
+		return nil, fmt.Errorf("allowlist %s: %w", source, err)
+	case len(bad) > 0:
+		return nil, fmt.Errorf("allowlist %s: %d entries are not CIDRs or IP addresses, e.g. %q", source, len(bad), bad[0])
+	}
+	return prefixes, nil
+}
+
+// fetchAllowlist downloads an --audit-allowlist URL with the network settings of the
+// meta data fetch (--proxy, --connect-timeout and --timeout or --read-timeout),
+// reading at most one byte more than maxAllowlistBytes.
+func (a *app) fetchAllowlist(ctx context.Context, src sourceOptions, rawURL string) ([]byte, error) {
+	fetchOpts := a.fetchOptions(src)
+	ctx, cancel := context.WithTimeout(ctx, src.requestTimeout())
+	defer cancel()
+	req, err := fetchOpts.NewRequest(ctx, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("allowlist: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain")
+	resp, err := fetchOpts.HTTPClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch allowlist: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch allowlist: unexpected status %d from %s", resp.StatusCode, rawURL)
+	}
+	// A login page or captive portal would otherwise fail as a pile of bad entries.
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return nil, errors.New("fetch allowlist: got an HTML page instead of a list of CIDRs")
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxAllowlistBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch allowlist: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// runCache implements "cache info" and "cache clear". Neither fetches anything.
+func (a *app) runCache(_ context.Context, args []string) int {
+	var src sourceOptions
+	fs := newFlagSet("cache", a.stderr)
+	fs.StringVar(&src.cacheDir, "cache-dir", "", cacheDirUsage)
+	fs.StringVar(&src.config, "config", "", "read --cache-dir from a key=value or JSON file unless given")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cidr-calculator-github cache [--cache-dir PATH] info|clear")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if fs.NArg() != 1 || (fs.Arg(0) != "info" && fs.Arg(0) != "clear") {
+		return usageExitCode(usageError(fs, "cache expects one action: info or clear"))
+	}
+	if err := src.parsed(fs); err != nil {
+		return usageExitCode(err)
+	}
+
+	dir, err := cacheDir(src)
+	if err == nil {
+		if fs.Arg(0) == "clear" {
+			err = clearCache(a.stdout, dir)
+		} else {
+			err = inspectCache(a.stdout, dir)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func clearCache(w io.Writer, dir string) error {
+	if err := githubmeta.ClearCache(dir); err != nil {
+		return fmt.Errorf("clear cache: %w", err)
+	}
+	fmt.Fprintf(w, "Cleared cache in %s\n", dir)
+	return nil
+}
+
+func inspectCache(w io.Writer, dir string) error {
+	info, err := githubmeta.InspectCache(dir)
+	if err != nil {
+		return fmt.Errorf("inspect cache: %w", err)
+	}
+	printCacheInfo(w, info)
+	return nil
+}
+
+func printCacheInfo(w io.Writer, info githubmeta.CacheInfo) {
+	fmt.Fprintf(w, "Cache directory: %s\n", info.Dir)
+	if info.HasMeta {
+		fmt.Fprintf(w, "meta.json: %d bytes (fetched %s)\n", info.MetaSize, info.FetchedAt.Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(w, "meta.json: not present")
+	}
+	if info.HasETag {
+		fmt.Fprintf(w, "meta.etag: %d bytes (%s)\n", info.ETagSize, info.ETag)
+	} else {
+		fmt.Fprintln(w, "meta.etag: not present")
+	}
+}
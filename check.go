@@ -0,0 +1,744 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// checkOptions holds the flags of the check subcommand.
+type checkOptions struct {
+	source sourceOptions
+	input  string
+	ptr    bool
+	asn    bool
+	detail bool
+	expand bool
+	format string
+	fields []string
+	tmpl   *template.Template
+	// jsonOut is the --json-out path.
+	jsonOut string
+	// warnReserved skips the lookup for special-purpose inputs.
+	warnReserved  bool
+	canonicalWarn bool
+	// compare is --compare: the two positional arguments are compared instead of checked.
+	compare bool
+	// aggregate adds a combined summary of every CIDR in the batch.
+	aggregate bool
+	numeric   bool
+	status    bool
+	// labelsOnly is --labels-only: just the labels, with exit status 1 when nothing matched.
+	labelsOnly  bool
+	bitmaskExit bool
+	// state is the --state checkpoint file for resuming a single CIDR evaluation.
+	state  string
+	timing bool
+	count  bool
+	// cover prints the smallest prefix containing every input instead of checking them.
+	cover bool
+	// checkInterfaces checks the local interface addresses instead of inputs.
+	checkInterfaces bool
+	// inputJSON is the --input-json file, a JSON array of inputs answered with a
+	// JSON array of results.
+	inputJSON string
+	// dumpRaw prints the loaded meta document instead of checking anything,
+	// indented when pretty is set.
+	dumpRaw bool
+	pretty  bool
+	// annotate copies the input with a labels column for the address in column,
+	// splitting lines on delimiter.
+	annotate  bool
+	column    int
+	delimiter string
+	// merge maps --merge source names to meta.json files checked alongside the
+	// loaded ranges; tagSource labels each range with its source.
+	merge     map[string]string
+	tagSource bool
+	// sortOutput is the --sort-output order of batch results.
+	sortOutput    string
+	groupByFamily bool
+	// noDisclaimer drops the "(based on ...)" qualifier from negative results.
+	noDisclaimer bool
+	// minPrefix and maxPrefix drop entries broader or narrower than these lengths; 0 is unbounded.
+	minPrefix int
+	maxPrefix int
+
+	limit       int
+	perAddress  bool
+	boundaries  bool
+	relation    bool
+	showUnowned bool
+	maxResults  int
+	sample      int
+	seed        int64
+	// seedSet is whether --seed was given; otherwise seed is time-based.
+	seedSet bool
+	// only is "misses" or "matches" for --only-misses / --only-matches.
+	only string
+}
+
+// inputRule says which inputs an option of checkModes needs.
+type inputRule int
+
+const (
+	anyInputs inputRule = iota
+	// needsInputs requires addresses or --input.
+	needsInputs
+	// noInputs refuses both addresses and --input.
+	noInputs
+	// noArgs refuses addresses but reads --input or stdin.
+	noArgs
+)
+
+// checkModes lists the check options that change what is checked or how it is
+// printed, with the inputs each needs and the options it cannot be combined with.
+// An incompatible pair is listed once, under the first of the two. The names are
+// the keys of checkOptionsSet.
+var checkModes = []struct {
+	name      string
+	inputs    inputRule
+	conflicts []string
+}{
+	// The raw document is only kept by a MetaData parsed from it, not by one that
+	// was filtered, merged or decoded from an --index file.
+	{"--dump-raw", noInputs, []string{"--input-json", "--format json", "--template", "--labels-only", "--count", "--cover", "--check-interfaces", "--annotate", "--aggregate", "--state",
+		"--merge", "--labels", "--runners", "--only-family", "--min-prefix", "--max-prefix", "--index"}},
+	// --input-json prints exactly one JSON element per input.
+	{"--input-json", noInputs, []string{"--template", "--labels-only", "--count", "--cover", "--compare", "--aggregate", "--state", "--annotate", "--check-interfaces",
+		"--sort-output", "--group-by-family", "--per-address", "--expand-labels", "--only-misses", "--only-matches"}},
+	{"--check-interfaces", noInputs, []string{"--format json", "--template", "--labels-only", "--count", "--cover", "--annotate", "--aggregate", "--state"}},
+	{"--annotate", noArgs, []string{"--format json", "--template", "--labels-only", "--count", "--cover", "--aggregate", "--state"}},
+	{"--cover", needsInputs, []string{"--format json", "--template", "--labels-only", "--count", "--compare", "--aggregate", "--state"}},
+	{"--state", anyInputs, []string{"--input", "--per-address", "--compare", "--aggregate", "--boundaries", "--relation", "--show-unowned", "--sort-output", "--group-by-family", "--count"}},
+	{"--compare", anyInputs, []string{"--input", "--fields", "--template", "--labels-only", "--bitmask-exit", "--sort-output", "--group-by-family", "--count"}},
+	{"--count", anyInputs, []string{"--format json", "--template", "--labels-only", "--per-address", "--aggregate"}},
+	{"--labels-only", anyInputs, []string{"--format json", "--template", "--aggregate", "--bitmask-exit", "--group-by-family"}},
+	{"--group-by-family", needsInputs, []string{"--format json", "--template"}},
+	{"--sort-output", needsInputs, nil},
+	{"--aggregate", needsInputs, []string{"--fields", "--template"}},
+	{"--template", anyInputs, []string{"--format json"}},
+}
+
+// checkOptionsSet reports which of the options named in checkModes are in effect.
+// fields and tmpl are whether --fields and --template or --template-file were given.
+func checkOptionsSet(opts checkOptions, fields, tmpl bool) map[string]bool {
+	return map[string]bool{
+		"--input":            opts.input != "",
+		"--input-json":       opts.inputJSON != "",
+		"--format json":      opts.format == "json",
+		"--fields":           fields,
+		"--template":         tmpl,
+		"--labels-only":      opts.labelsOnly,
+		"--bitmask-exit":     opts.bitmaskExit,
+		"--count":            opts.count,
+		"--cover":            opts.cover,
+		"--check-interfaces": opts.checkInterfaces,
+		"--annotate":         opts.annotate,
+		"--dump-raw":         opts.dumpRaw,
+		"--compare":          opts.compare,
+		"--aggregate":        opts.aggregate,
+		"--state":            opts.state != "",
+		"--per-address":      opts.perAddress,
+		"--boundaries":       opts.boundaries,
+		"--relation":         opts.relation,
+		"--show-unowned":     opts.showUnowned,
+		"--expand-labels":    opts.expand,
+		"--only-misses":      opts.only == "misses",
+		"--only-matches":     opts.only == "matches",
+		"--sort-output":      opts.sortOutput != "input",
+		"--group-by-family":  opts.groupByFamily,
+		"--merge":            len(opts.merge) > 0,
+		"--labels":           opts.source.labels != "",
+		"--runners":          opts.source.runners,
+		"--only-family":      opts.source.onlyFamily != "",
+		"--index":            opts.source.index != "",
+		"--min-prefix":       opts.minPrefix > 0,
+		"--max-prefix":       opts.maxPrefix > 0,
+	}
+}
+
+// checkCombinations enforces checkModes against the parsed options.
+func checkCombinations(fs *flag.FlagSet, opts checkOptions, fields, tmpl bool) error {
+	set := checkOptionsSet(opts, fields, tmpl)
+	hasInputs := fs.NArg() > 0 || opts.input != ""
+	for _, mode := range checkModes {
+		if !set[mode.name] {
+			continue
+		}
+		switch {
+		case mode.inputs == needsInputs && !hasInputs:
+			return usageError(fs, "%s requires addresses or --input", mode.name)
+		case mode.inputs == noInputs && hasInputs:
+			return usageError(fs, "%s takes no addresses or --input", mode.name)
+		case mode.inputs == noArgs && fs.NArg() > 0:
+			return usageError(fs, "%s reads --input or stdin and takes no addresses", mode.name)
+		}
+		for _, other := range mode.conflicts {
+			if set[other] {
+				return usageError(fs, "%s cannot be combined with %s", mode.name, other)
+			}
+		}
+	}
+	return nil
+}
+
+func parseCheckOptions(args []string, stderr io.Writer) (checkOptions, []string, error) {
+	var opts checkOptions
+	fs := newFlagSet("check", stderr)
+	opts.source.addFlags(fs)
+	fs.StringVar(&opts.input, "input", "", "read addresses to check from a file, one per line ('-' for stdin)")
+	fs.StringVar(&opts.inputJSON, "input-json", "", "read a JSON array of addresses and CIDRs from a file ('-' for stdin) and print a JSON array of results")
+	fs.BoolVar(&opts.ptr, "ptr", false, "also print reverse DNS (PTR) names for each address")
+	fs.BoolVar(&opts.asn, "asn", false, "flag addresses missing from the meta data that fall in GitHub's ASN (AS36459) space")
+	fs.BoolVar(&opts.detail, "detail", false, "list the matching prefix(es) for each label of an owned address")
+	fs.BoolVar(&opts.status, "status", false, "start each CIDR result with FULL, PARTIAL, NONE, TOOLARGE or INVALID (\"status\" in JSON)")
+	fs.BoolVar(&opts.canonicalWarn, "canonical-warn", false, "note addresses not written in canonical form, such as 2001:DB8:0:0::1 for 2001:db8::1")
+	fs.BoolVar(&opts.warnReserved, "warn-reserved", false, "report private, loopback, link-local and multicast inputs as reserved instead of looking them up")
+	fs.BoolVar(&opts.expand, "expand-labels", false, "write one result per matching label instead of one per address")
+	fs.StringVar(&opts.format, "format", "text", "output format: text or json (one JSON object per line)")
+	fs.StringVar(&opts.jsonOut, "json-out", "", "also write every result as a JSON line to FILE, alongside the normal output")
+	fields := fs.String("fields", "", "comma-separated JSON fields to include, e.g. input,owned,labels (requires --format json or --json-out)")
+	tmplText := fs.String("template", "", "render each result with a Go text/template, e.g. '{{.Input}} {{join .Labels \",\"}}'")
+	tmplFile := fs.String("template-file", "", "read the --template text from a file")
+	fs.IntVar(&opts.limit, "limit", defaultCIDRLimit, "largest CIDR, in addresses, that will be evaluated")
+	fs.BoolVar(&opts.perAddress, "per-address", false, "print a result for every address of a CIDR before its summary")
+	fs.BoolVar(&opts.relation, "relation", false, "report whether each CIDR is equal to, a subset of or a superset of each published prefix it overlaps")
+	fs.BoolVar(&opts.showUnowned, "show-unowned", false, "list the fewest CIDRs covering the addresses of each CIDR that GitHub does not own")
+	fs.BoolVar(&opts.boundaries, "boundaries", false, "report the first and last owned address of each CIDR and every address where ownership changes")
+	fs.IntVar(&opts.maxResults, "max-results", 0, "stop per-address output after N rows (0 means no cap); summaries still cover every address")
+	fs.IntVar(&opts.sample, "sample", 0, "estimate CIDRs over --limit from N random addresses instead of refusing them")
+	fs.Int64Var(&opts.seed, "seed", 0, "seed for --sample, for reproducible estimates (default: time-based, printed to stderr)")
+	fs.BoolVar(&opts.aggregate, "aggregate", false, "after the per-input results, summarise all CIDRs together, counting overlapping addresses once")
+	fs.BoolVar(&opts.numeric, "numeric-output", false, "also print each address as a big-endian integer, e.g. 192.30.252.42 (3223256106)")
+	fs.BoolVar(&opts.labelsOnly, "labels-only", false, "print only the matched labels, one per line; exit 1 when nothing matched")
+	fs.BoolVar(&opts.bitmaskExit, "bitmask-exit", false, "exit with a bitmask of outcomes: 1 = any owned, 2 = any not owned, 4 = any invalid")
+	fs.StringVar(&opts.state, "state", "", "checkpoint a single CIDR's evaluation to FILE and resume from it when rerun")
+	fs.BoolVar(&opts.count, "count", false, "print only the number of owned addresses of each input (1 or 0 for an address), with no --limit")
+	fs.Func("merge", "also check against the meta.json `NAME=FILE`, e.g. ghes.example=ghes-meta.json (repeatable)", func(v string) error {
+		name, path, ok := strings.Cut(v, "=")
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("expected NAME=FILE")
+		}
+		if opts.merge == nil {
+			opts.merge = map[string]string{}
+		}
+		if _, dup := opts.merge[name]; dup {
+			return fmt.Errorf("source %q given twice", name)
+		}
+		opts.merge[name] = path
+		return nil
+	})
+	fs.BoolVar(&opts.tagSource, "tag-source", false, "with --merge, label ranges as LABEL@SOURCE, e.g. web@github.com")
+	fs.BoolVar(&opts.cover, "cover", false, "print the smallest CIDR containing every address given as arguments or in --input, without checking them")
+	fs.BoolVar(&opts.annotate, "annotate", false, "copy each line of --input (or stdin) with a column of labels, or -, for the address in --column")
+	fs.IntVar(&opts.column, "column", 0, "with --annotate, the column holding the address, counting from 1")
+	fs.StringVar(&opts.delimiter, "delimiter", "\t", "with --annotate, the column delimiter")
+	fs.BoolVar(&opts.checkInterfaces, "check-interfaces", false, "check this machine's global unicast interface addresses and report any owned by GitHub")
+	fs.BoolVar(&opts.dumpRaw, "dump-raw", false, "print the meta JSON exactly as fetched, cached or read, and exit")
+	fs.BoolVar(&opts.pretty, "pretty", false, "with --dump-raw, indent the JSON")
+	fs.StringVar(&opts.sortOutput, "sort-output", "input", "order of batch results: input, address (numeric) or ownership (owned, not owned, invalid)")
+	fs.BoolVar(&opts.groupByFamily, "group-by-family", false, "write batch results in IPv4 and IPv6 sections, each with a subtotal")
+	fs.BoolVar(&opts.noDisclaimer, "no-disclaimer", false, "omit the \"(based on current meta data)\" note from results that are not owned")
+	fs.BoolVar(&opts.timing, "timing", false, "print to stderr how long fetching and evaluation took")
+	fs.BoolVar(&opts.compare, "compare", false, "report the labels two addresses share and those only one has: --compare A B")
+	fs.IntVar(&opts.minPrefix, "min-prefix", 0, "ignore meta entries shorter than /N, e.g. 24 to skip broad blocks")
+	fs.IntVar(&opts.maxPrefix, "max-prefix", 0, "ignore meta entries longer than /N (0 means no limit)")
+	onlyMisses := fs.Bool("only-misses", false, "print only addresses not owned by GitHub (and invalid inputs), then a tally")
+	onlyMatches := fs.Bool("only-matches", false, "print only addresses owned by GitHub (and invalid inputs), then a tally")
+	if err := fs.Parse(args); err != nil {
+		return opts, nil, err
+	}
+	switch {
+	case *onlyMisses && *onlyMatches:
+		return opts, nil, usageError(fs, "--only-misses and --only-matches are mutually exclusive")
+	case *onlyMisses:
+		opts.only = "misses"
+	case *onlyMatches:
+		opts.only = "matches"
+	}
+	if *tmplText != "" && *tmplFile != "" {
+		return opts, nil, usageError(fs, "--template and --template-file are mutually exclusive")
+	}
+	if !slices.Contains(sortOrders, opts.sortOutput) {
+		return opts, nil, usageError(fs, "invalid --sort-output %q (expected %s)", opts.sortOutput, strings.Join(sortOrders, ", "))
+	}
+	// The --config file can set source options such as labels, so it is applied
+	// before the combinations are checked.
+	if err := opts.source.parsed(fs); err != nil {
+		return opts, nil, err
+	}
+	if err := checkCombinations(fs, opts, *fields != "", *tmplText != "" || *tmplFile != ""); err != nil {
+		return opts, nil, err
+	}
+	if opts.compare && fs.NArg() != 2 {
+		return opts, nil, usageError(fs, "--compare expects exactly two addresses")
+	}
+	if opts.state != "" && (fs.NArg() != 1 || !strings.Contains(fs.Arg(0), "/")) {
+		return opts, nil, usageError(fs, "--state expects exactly one CIDR argument")
+	}
+	if opts.tagSource && len(opts.merge) == 0 {
+		return opts, nil, usageError(fs, "--tag-source requires --merge")
+	}
+	if opts.pretty && !opts.dumpRaw {
+		return opts, nil, usageError(fs, "--pretty requires --dump-raw")
+	}
+	// A literal \t is easier to pass from a shell than a tab.
+	if opts.delimiter == `\t` {
+		opts.delimiter = "\t"
+	}
+	annotateFlag := false
+	fs.Visit(func(f *flag.Flag) { annotateFlag = annotateFlag || f.Name == "column" || f.Name == "delimiter" })
+	if annotateFlag && !opts.annotate {
+		return opts, nil, usageError(fs, "--column and --delimiter require --annotate")
+	}
+	if opts.annotate && (opts.column < 1 || opts.delimiter == "") {
+		return opts, nil, usageError(fs, "--annotate requires --column N (counting from 1) and a non-empty --delimiter")
+	}
+	if *tmplText != "" || *tmplFile != "" {
+		text := *tmplText
+		if *tmplFile != "" {
+			data, err := os.ReadFile(*tmplFile)
+			if err != nil {
+				return opts, nil, usageError(fs, "read --template-file: %v", err)
+			}
+			text = string(data)
+		}
+		var err error
+		if opts.tmpl, err = parseTemplate(text); err != nil {
+			return opts, nil, usageError(fs, "invalid template: %v", err)
+		}
+	}
+	if opts.limit < 1 {
+		return opts, nil, usageError(fs, "--limit must be at least 1")
+	}
+	if opts.minPrefix < 0 || opts.maxPrefix < 0 || opts.minPrefix > 128 || opts.maxPrefix > 128 {
+		return opts, nil, usageError(fs, "--min-prefix and --max-prefix must be between 0 and 128")
+	}
+	if opts.maxPrefix > 0 && opts.minPrefix > opts.maxPrefix {
+		return opts, nil, usageError(fs, "--min-prefix %d is greater than --max-prefix %d", opts.minPrefix, opts.maxPrefix)
+	}
+	if opts.maxResults < 0 {
+		return opts, nil, usageError(fs, "--max-results must not be negative")
+	}
+	if opts.sample < 0 {
+		return opts, nil, usageError(fs, "--sample must not be negative")
+	}
+	fs.Visit(func(f *flag.Flag) {
+		opts.seedSet = opts.seedSet || f.Name == "seed"
+	})
+	if !opts.seedSet {
+		opts.seed = time.Now().UnixNano()
+	}
+	if opts.inputJSON != "" {
+		// The results are always JSON.
+		opts.format = "json"
+	}
+	if opts.format != "text" && opts.format != "json" {
+		return opts, nil, usageError(fs, "invalid --format %q (expected text or json)", opts.format)
+	}
+	if *fields != "" {
+		if opts.format != "json" && opts.jsonOut == "" {
+			return opts, nil, usageError(fs, "--fields requires --format json or --json-out")
+		}
+		var err error
+		if opts.fields, err = parseFields(*fields, reflect.TypeOf(addrResult{}), reflect.TypeOf(cidrResult{})); err != nil {
+			return opts, nil, usageError(fs, "invalid --fields: %v", err)
+		}
+	}
+	return opts, fs.Args(), nil
+}
+
+// runCheck implements the check subcommand: it evaluates the arguments and any
+// --input file, or reads addresses interactively when there are none.
+func (a *app) runCheck(ctx context.Context, args []string) (code int) {
+	opts, args, err := parseCheckOptions(args, a.stderr)
+	if err != nil {
+		return usageExitCode(err)
+	}
+	if opts.cover {
+		return a.runCover(args, opts.input)
+	}
+
+	c := &checker{out: a.stdout, format: opts.format, fields: opts.fields, tmpl: opts.tmpl,
+		limit: opts.limit, perAddress: opts.perAddress, boundaries: opts.boundaries, relation: opts.relation, showUnowned: opts.showUnowned, maxResults: opts.maxResults, detail: opts.detail, only: opts.only, expandLabels: opts.expand, warnReserved: opts.warnReserved, canonicalWarn: opts.canonicalWarn, sortOutput: opts.sortOutput, groupByFamily: opts.groupByFamily, aggregate: opts.aggregate, numeric: opts.numeric, status: opts.status, labelsOnly: opts.labelsOnly, count: opts.count, bitmaskExit: opts.bitmaskExit,
+		sample: opts.sample, rnd: rand.New(rand.NewSource(opts.seed))}
+	// The generator is used only by --sample. Print a time-based seed so that the
+	// run can be reproduced.
+	if opts.sample > 0 && !opts.seedSet {
+		fmt.Fprintf(a.stderr, "sample: --seed %d\n", opts.seed)
+	}
+	// Progress messages go to stderr for JSON and templates so stdout stays machine-readable.
+	info := a.stdout
+	if !c.prose() || opts.annotate || opts.dumpRaw {
+		info = a.stderr
+	}
+	if opts.ptr {
+		c.resolver = a.resolver
+		if c.resolver == nil {
+			c.resolver = net.DefaultResolver
+		}
+	}
+	if opts.asn {
+		c.asn = a.asnPrefixes
+		if c.asn == nil {
+			c.asn = githubASNPrefixes
+		}
+	}
+	start := time.Now()
+	c.meta, c.disclaimer, err = a.loadMeta(ctx, opts.source, info)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	if opts.dumpRaw {
+		return a.dumpRaw(c.meta, opts.pretty)
+	}
+	if opts.noDisclaimer {
+		c.disclaimer = ""
+	}
+	if len(opts.merge) > 0 {
+		if c.meta, err = mergeSources(c.meta, opts.source, opts.merge, opts.tagSource); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+	if opts.minPrefix > 0 || opts.maxPrefix > 0 {
+		c.meta = c.meta.FilterPrefixLen(opts.minPrefix, opts.maxPrefix)
+	}
+	if opts.timing {
+		fmt.Fprintf(a.stderr, "timing: fetch took %s (source: %s)\n", time.Since(start).Round(time.Microsecond), c.meta.Source())
+		start = time.Now()
+		defer func() {
+			elapsed := time.Since(start)
+			fmt.Fprintf(a.stderr, "timing: evaluated %d addresses in %s (%.0f addresses/sec)\n",
+				c.checked, elapsed.Round(time.Microsecond), float64(c.checked)/elapsed.Seconds())
+		}()
+	}
+	if opts.jsonOut != "" {
+		f, err := os.Create(opts.jsonOut)
+		if err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		defer func() {
+			if err := f.Close(); err != nil && code == 0 {
+				fmt.Fprintf(a.stderr, "error: write --json-out: %v\n", err)
+				code = 1
+			}
+		}()
+		c.jsonOut = f
+	}
+
+	if opts.state != "" {
+		if err := c.evaluateCIDRWithState(ctx, args[0], opts.state); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return c.exitCode()
+	}
+	if opts.annotate {
+		if err := a.annotate(c.meta, opts.input, opts.column, opts.delimiter); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if opts.checkInterfaces {
+		if err := a.checkInterfaces(c.meta); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if opts.compare {
+		if err := c.compare(args[0], args[1]); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if opts.inputJSON != "" {
+		if err := a.evaluateJSONArray(c, opts.inputJSON); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return c.exitCode()
+	}
+	if len(args) > 0 || opts.input != "" {
+		for _, arg := range args {
+			for _, input := range splitInputs(arg) {
+				c.evaluateBatchInput(input)
+			}
+		}
+		if opts.input != "" {
+			if err := a.evaluateFile(c, opts.input); err != nil {
+				c.flushSorted()
+				fmt.Fprintf(a.stderr, "error: %v\n", err)
+				return 1
+			}
+		}
+		c.flushSorted()
+		if c.aggregate {
+			c.emitAggregate()
+		}
+		// With a filter some results are hidden, so account for all of them.
+		if c.only != "" {
+			fmt.Fprintf(info, "Summary: %s\n", c.tally)
+		}
+		return c.exitCode()
+	}
+
+	// Piped input is processed quietly, like a filter; only a terminal gets prompts.
+	interactive := isTerminal(a.stdin)
+	if interactive {
+		fmt.Fprintln(info, "Enter an IP address to check ('? PREFIX' lists matching labels, 'exit' to quit):")
+	}
+	scanner := newInputScanner(a.stdin)
+	for {
+		if interactive {
+			fmt.Fprint(info, "> ")
+		}
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				fmt.Fprintf(a.stderr, "input error: %v\n", inputLineError(err))
+				return 1
+			}
+			break
+		}
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+		if strings.EqualFold(input, "exit") || strings.EqualFold(input, "quit") {
+			break
+		}
+		if partial, ok := strings.CutPrefix(input, "?"); ok {
+			for _, label := range c.meta.CompleteLabel(strings.TrimSpace(partial)) {
+				fmt.Fprintln(info, label)
+			}
+			continue
+		}
+		for _, input := range splitInputs(input) {
+			c.evaluateInput(input)
+		}
+	}
+	return c.exitCode()
+}
+
+// dumpRaw implements --dump-raw: it prints the document meta was parsed from,
+// indented when pretty is set.
+func (a *app) dumpRaw(meta *githubmeta.MetaData, pretty bool) int {
+	raw := meta.RawJSON()
+	if raw == nil {
+		fmt.Fprintln(a.stderr, "error: the meta data was not loaded from a JSON document")
+		return 1
+	}
+	if pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, raw, "", "  "); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		raw = buf.Bytes()
+	}
+	a.stdout.Write(raw)
+	fmt.Fprintln(a.stdout)
+	return 0
+}
+
+// isTerminal reports whether r is a character device such as a terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// evaluateFile checks every non-empty, non-comment line of path ("-" reads stdin).
+func (a *app) evaluateFile(c *checker, path string) error {
+	return a.readInputFile(path, c.evaluateBatchInput)
+}
+
+// evaluateJSONArray implements --input-json: it checks every string of the JSON array
+// in path ("-" reads stdin), each as one input, and writes the results as a single
+// JSON array. An element that is not a string gets an invalid result of its own
+// instead of failing the batch.
+func (a *app) evaluateJSONArray(c *checker, path string) error {
+	var r io.Reader = a.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var elems []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&elems); err != nil {
+		return fmt.Errorf("--input-json expects a JSON array of strings: %w", err)
+	}
+
+	// parseCheckOptions rules out the options that print more or fewer than one
+	// result per input, so each element yields exactly one.
+	results := make([]any, 0, len(elems))
+	var input string
+	c.collect = func(res any) { results = append(results, c.selectedFields(input, res)) }
+	defer func() { c.collect = nil }()
+	for _, elem := range elems {
+		var raw string
+		if err := json.Unmarshal(elem, &raw); err != nil {
+			input = string(elem)
+			c.emitInvalid(input, errors.New("not a JSON string"))
+			continue
+		}
+		input = strings.TrimSpace(raw)
+		c.evaluateInput(input)
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode results: %w", err)
+	}
+	fmt.Fprintf(c.out, "%s\n", data)
+	return nil
+}
+
+// mergeSources implements --merge: it merges the loaded ranges, named after the
+// endpoint's host (github.com by default), with each named meta.json file, labelling
+// the ranges LABEL@SOURCE when tag is set.
+func mergeSources(meta *githubmeta.MetaData, src sourceOptions, files map[string]string, tag bool) (*githubmeta.MetaData, error) {
+	primary := "github.com"
+	if u, err := url.Parse(src.endpoint); err == nil && u.Hostname() != "" {
+		primary = u.Hostname()
+	}
+	sources := map[string]*githubmeta.MetaData{primary: meta}
+	for name, path := range files {
+		if _, dup := sources[name]; dup {
+			return nil, fmt.Errorf("--merge source %q is already the name of the loaded ranges", name)
+		}
+		other, err := githubmeta.LoadFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources[name] = filterSource(other, src)
+	}
+	merged := githubmeta.MergeMeta(sources)
+	if tag {
+		merged = merged.TagSources()
+	}
+	return merged, nil
+}
+
+// readInputFile calls each for every input on the non-empty, non-comment lines of
+// path ("-" reads stdin).
+func (a *app) readInputFile(path string, each func(string)) error {
+	var r io.Reader = a.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return readInputs(r, each)
+}
+
+// maxInputLine is the longest input line read from stdin or an --input file. It is
+// far above any sensible list of addresses on one line but keeps a runaway input
+// from growing the line buffer without bound.
+const maxInputLine = 1 << 20
+
+// newInputScanner returns a line scanner over r that accepts lines of up to
+// maxInputLine bytes rather than bufio's default of 64 KiB.
+func newInputScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputLine)
+	return scanner
+}
+
+// inputLineError rewords the error bufio.Scanner stops with on a line longer than
+// maxInputLine, which otherwise reads "token too long".
+func inputLineError(err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("input line too long (the limit is %d bytes)", maxInputLine)
+	}
+	return err
+}
+
+// readInputs calls each for every input on the non-empty, non-comment lines of r.
+func readInputs(r io.Reader, each func(string)) error {
+	scanner := newInputScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, input := range splitInputs(line) {
+			each(input)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read input: %w", inputLineError(err))
+	}
+	return nil
+}
+
+// runCover prints the smallest prefix containing the addresses in args and the
+// --input file. No meta data is needed.
+func (a *app) runCover(args []string, input string) int {
+	var inputs []string
+	for _, arg := range args {
+		inputs = append(inputs, splitInputs(arg)...)
+	}
+	if input != "" {
+		if err := a.readInputFile(input, func(s string) { inputs = append(inputs, s) }); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+	addrs := make([]netip.Addr, 0, len(inputs))
+	for _, raw := range inputs {
+		addr, err := netip.ParseAddr(raw)
+		if err != nil {
+			fmt.Fprintf(a.stderr, "error: --cover takes IP addresses: %v\n", err)
+			return 1
+		}
+		addrs = append(addrs, addr)
+	}
+	prefix, err := githubmeta.MinimalCoveringPrefix(addrs)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(a.stdout, prefix)
+	return 0
+}
+
+// splitInputs splits a pasted list such as "192.30.252.42, 140.82.112.5 8.8.8.8" on
+// commas and whitespace. A single address or CIDR comes back unchanged.
+func splitInputs(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
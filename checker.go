@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// checker evaluates inputs against a loaded meta data set and prints the results.
+type checker struct {
+	meta *githubmeta.MetaData
+	out  io.Writer
+	// disclaimer qualifies negative results with the data source, e.g. "based on current
+	// meta data"; --no-disclaimer leaves it empty.
+	disclaimer string
+	// resolver is set when --ptr is enabled.
+	resolver ptrResolver
+	// format is "text" or "json"; fields optionally restricts the JSON keys.
+	format string
+	fields []string
+	// tmpl, when set, renders every result instead of format.
+	tmpl *template.Template
+	// jsonOut, set by --json-out, additionally receives every result as a JSON line.
+	jsonOut io.Writer
+	// summaryOut is the real output while evaluateCIDR holds back the lines that
+	// would precede a --status summary; see evaluateCIDR.
+	summaryOut io.Writer
+	// collect, when set, receives every address and CIDR result in place of any
+	// output, for callers that need the results as values.
+	collect func(res any)
+	// limit caps how many addresses a CIDR input may expand to.
+	limit int
+	// perAddress prints a row for every address of a CIDR input, at most maxResults
+	// rows when maxResults is positive.
+	perAddress bool
+	maxResults int
+	// boundaries adds where ownership starts, ends and flips to CIDR summaries.
+	boundaries bool
+	// relation adds how each CIDR relates to the published prefixes it overlaps.
+	relation bool
+	// showUnowned adds the sub-blocks of each CIDR that GitHub does not own.
+	showUnowned bool
+	// sample, when positive, estimates CIDRs over limit from that many random
+	// addresses drawn with rnd instead of refusing them.
+	sample int
+	rnd    *rand.Rand
+	// detail lists, under each owned address, which prefixes matched each label.
+	detail bool
+	// warnReserved short-circuits private, loopback and other special-purpose inputs.
+	warnReserved bool
+	// canonicalWarn notes addresses not written in canonical form.
+	canonicalWarn bool
+	// expandLabels writes one result per matching label instead of one per address.
+	expandLabels bool
+	// asn, set by --asn, is checked for addresses missing from the meta data.
+	asn []asnPrefix
+	// only hides owned ("misses") or not-owned ("matches") address results; invalid
+	// inputs are always shown. tally counts every address result either way.
+	only  string
+	tally tally
+	// aggregate, set by --aggregate, records every fully evaluated CIDR in aggregated
+	// for a combined summary at the end of the batch.
+	aggregate  bool
+	aggregated []netip.Prefix
+	// numeric adds the integer form of each address to its result.
+	numeric bool
+	// status leads CIDR results with a one-word classification; see cidrStatus.
+	status bool
+	// labelsOnly prints just the matched labels, one per line; matched records
+	// whether any were printed.
+	labelsOnly bool
+	matched    bool
+	// bitmaskExit is --bitmask-exit; outcomes accumulates the exit* bits it reports.
+	bitmaskExit bool
+	outcomes    int
+	// count is --count: each input prints only its number of owned addresses.
+	count bool
+	// checked counts the addresses looked up, for --timing.
+	checked int64
+	// sortOutput is the --sort-output order; sorted buffers batch output until
+	// flushSorted writes it.
+	sortOutput string
+	sorted     []*sortedOutput
+	// groupByFamily writes batch output in IPv4 and IPv6 sections with subtotals.
+	groupByFamily bool
+}
+
+// Exit status bits of --bitmask-exit.
+const (
+	exitOwned = 1 << iota
+	exitNotOwned
+	exitInvalid
+)
+
+// tally counts address results by outcome.
+type tally struct {
+	owned, notOwned, invalid int
+}
+
+func (t *tally) add(res addrResult) {
+	switch {
+	case res.Error != "":
+		t.invalid++
+	case res.Owned:
+		t.owned++
+	default:
+		t.notOwned++
+	}
+}
+
+// addCIDR counts res as one result: invalid when it could not be evaluated, owned
+// when GitHub owns any of its addresses.
+func (t *tally) addCIDR(res cidrResult) {
+	switch {
+	case res.Error != "":
+		t.invalid++
+	case res.OwnedCount > 0:
+		t.owned++
+	default:
+		t.notOwned++
+	}
+}
+
+func (t tally) String() string {
+	return fmt.Sprintf("%d checked: %d owned, %d not owned, %d invalid", t.owned+t.notOwned+t.invalid, t.owned, t.notOwned, t.invalid)
+}
+
+// addrResult is the structured outcome of evaluating one input.
+type addrResult struct {
+	Input    string   `json:"input"`
+	Address  string   `json:"address,omitempty"`
+	Owned    bool     `json:"owned"`
+	Labels   []string `json:"labels"`
+	Prefixes []string `json:"prefixes"`
+	// Matches pairs each label with the prefixes that matched it.
+	Matches []labelMatch `json:"matches,omitempty"`
+	PTR     []string     `json:"ptr,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	// Reason classifies Error for scripts, e.g. "bad_octet".
+	Reason githubmeta.ParseReason `json:"reason,omitempty"`
+	// ASN names the GitHub autonomous system announcing a not-owned address (--asn).
+	ASN string `json:"asn,omitempty"`
+	// Reserved names the special-purpose scope, e.g. "private", of an input that
+	// --warn-reserved kept from being looked up.
+	Reserved string `json:"reserved,omitempty"`
+	// Numeric is the integer form of Address, set by --numeric-output.
+	Numeric *big.Int `json:"numeric,omitempty"`
+	// NonCanonical is set by --canonical-warn when Input is not written the way
+	// Address is, e.g. "2001:DB8:0:0::1" for "2001:db8::1".
+	NonCanonical bool `json:"non_canonical,omitempty"`
+
+	addr   netip.Addr
+	ptrErr error
+}
+
+// labelMatch is one label of an addrResult with the prefixes that matched it.
+type labelMatch struct {
+	Label    string   `json:"label"`
+	Prefixes []string `json:"prefixes"`
+}
+
+func (c *checker) evaluateInput(raw string) {
+	if c.count {
+		c.countInput(raw)
+		return
+	}
+	if strings.Contains(raw, "/") {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			c.emitInvalid(raw, err)
+			return
+		}
+		c.evaluateCIDR(raw, prefix)
+		return
+	}
+
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		c.emitInvalid(raw, err)
+		return
+	}
+	c.evaluateAddr(raw, addr)
+}
+
+// countInput implements --count: it prints the number of owned addresses of raw as
+// a bare integer, which for a single address is 1 or 0. CIDRs are counted from the
+// overlapping prefixes, so --limit does not apply.
+func (c *checker) countInput(raw string) {
+	var prefix netip.Prefix
+	var err error
+	if strings.Contains(raw, "/") {
+		prefix, err = netip.ParsePrefix(raw)
+	} else {
+		var addr netip.Addr
+		if addr, err = netip.ParseAddr(raw); err == nil {
+			addr = addr.WithZone("")
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+	}
+	if err != nil {
+		c.emitInvalid(raw, err)
+		return
+	}
+
+	owned := c.meta.OwnedCount(prefix)
+	if owned.Sign() > 0 {
+		c.outcomes |= exitOwned
+	}
+	if owned.Cmp(githubmeta.PrefixSize(prefix)) < 0 {
+		c.outcomes |= exitNotOwned
+	}
+	fmt.Fprintln(c.out, owned)
+}
+
+func (c *checker) emitInvalid(raw string, err error) {
+	c.emit(invalidResult(raw, err))
+}
+
+// invalidResult describes an input that failed to parse, classifying why.
+func invalidResult(raw string, err error) addrResult {
+	reason := githubmeta.ClassifyInput(raw)
+	if reason == "" {
+		reason = githubmeta.ReasonNotAnIP
+	}
+	return addrResult{Input: raw, Labels: []string{}, Prefixes: []string{}, Error: err.Error(), Reason: reason}
+}
+
+func (c *checker) evaluateAddr(raw string, addr netip.Addr) {
+	nonCanonical := c.canonicalWarn && raw != addr.String()
+	if c.warnReserved {
+		if kind := reservedKind(addr); kind != "" {
+			c.emit(addrResult{Input: raw, Address: addr.String(), Labels: []string{}, Prefixes: []string{}, Reserved: kind, NonCanonical: nonCanonical, addr: addr})
+			return
+		}
+	}
+	res := c.lookup(raw, addr)
+	res.NonCanonical = nonCanonical
+	c.checked++
+	if c.resolver != nil {
+		res.PTR, res.ptrErr = c.lookupPTR(addr)
+	}
+	c.emit(res)
+}
+
+// lookup builds the result for a single address without any PTR data.
+func (c *checker) lookup(raw string, addr netip.Addr) addrResult {
+	res := addrResult{
+		Input:    raw,
+		Address:  addr.String(),
+		Labels:   c.meta.Lookup(addr),
+		Prefixes: []string{},
+		addr:     addr,
+	}
+	for _, entry := range c.meta.LookupEntries(addr) {
+		prefix := entry.Prefix.String()
+		if !slices.Contains(res.Prefixes, prefix) {
+			res.Prefixes = append(res.Prefixes, prefix)
+		}
+		// LookupEntries is ordered by label, so a label's prefixes are contiguous.
+		if n := len(res.Matches); n > 0 && res.Matches[n-1].Label == entry.Label {
+			res.Matches[n-1].Prefixes = append(res.Matches[n-1].Prefixes, prefix)
+		} else {
+			res.Matches = append(res.Matches, labelMatch{Label: entry.Label, Prefixes: []string{prefix}})
+		}
+	}
+	if res.Labels == nil {
+		// Meta data without entries; keep "labels": [] in JSON.
+		res.Labels = []string{}
+	}
+	res.Owned = len(res.Labels) > 0
+	if !res.Owned && c.asn != nil {
+		res.ASN = lookupASN(c.asn, addr)
+	}
+	return res
+}
+
+// prose reports whether output is free-form text, so informational notes may be
+// interleaved with results.
+func (c *checker) prose() bool {
+	return c.format == "text" && c.tmpl == nil && !c.labelsOnly && !c.count && c.collect == nil
+}
+
+// hidden reports whether --only-misses or --only-matches suppresses res.
+func (c *checker) hidden(res addrResult) bool {
+	if res.Error != "" {
+		return false
+	}
+	return (c.only == "misses" && res.Owned) || (c.only == "matches" && !res.Owned)
+}
+
+// emit records res and writes it unless --only-misses or --only-matches hides it,
+// reporting whether it was written.
+func (c *checker) emit(res addrResult) bool {
+	c.tally.add(res)
+	return c.emitRow(res)
+}
+
+// emitRow is emit without the tally, for the --per-address rows of a CIDR: the
+// tally counts inputs, and the CIDR's own result already stands for them.
+func (c *checker) emitRow(res addrResult) bool {
+	switch {
+	case res.Error != "":
+		c.outcomes |= exitInvalid
+	case res.Owned:
+		c.outcomes |= exitOwned
+	default:
+		c.outcomes |= exitNotOwned
+	}
+	if c.hidden(res) {
+		return false
+	}
+	if c.numeric && res.addr.IsValid() {
+		res.Numeric = githubmeta.AddrToInt(res.addr)
+	}
+	if c.expandLabels && len(res.Matches) > 1 {
+		for _, match := range res.Matches {
+			one := res
+			one.Labels = []string{match.Label}
+			one.Prefixes = match.Prefixes
+			one.Matches = []labelMatch{match}
+			c.write(one)
+		}
+		return true
+	}
+	c.write(res)
+	return true
+}
+
+// writeLabels prints labels one per line for --labels-only.
+func (c *checker) writeLabels(labels []string) {
+	for _, label := range labels {
+		fmt.Fprintln(c.out, label)
+		c.matched = true
+	}
+}
+
+// exitCode is the status of a finished run: the outcome bits under --bitmask-exit,
+// 1 under --labels-only when no label was printed, like grep without a match, and 0
+// otherwise.
+func (c *checker) exitCode() int {
+	if c.bitmaskExit {
+		return c.outcomes
+	}
+	if c.labelsOnly && !c.matched {
+		return 1
+	}
+	return 0
+}
+
+// displayAddr formats the address of res for text output, followed by its integer
+// form in parentheses when --numeric-output set one.
+func displayAddr(res addrResult) string {
+	if res.Numeric == nil {
+		return res.addr.String()
+	}
+	return fmt.Sprintf("%s (%s)", res.addr, res.Numeric)
+}
+
+// write prints a single address result in the configured output format(s).
+func (c *checker) write(res addrResult) {
+	if c.jsonOut != nil {
+		c.emitJSON(c.jsonOut, res.Input, res)
+	}
+	if c.collect != nil {
+		c.collect(res)
+		return
+	}
+	if c.tmpl != nil {
+		c.emitTemplate(res)
+		return
+	}
+	if c.format == "json" {
+		c.emitJSON(c.out, res.Input, res)
+		return
+	}
+	if c.labelsOnly {
+		c.writeLabels(res.Labels)
+		return
+	}
+
+	// A CIDR that does not parse ends up here rather than in emitCIDR.
+	if c.status && res.Error != "" && strings.Contains(res.Input, "/") {
+		fmt.Fprint(c.out, statusInvalid+" ")
+	}
+	if res.NonCanonical {
+		fmt.Fprintf(c.out, "note: %s is not in canonical form (%s)\n", res.Input, res.Address)
+	}
+	switch {
+	case res.Error != "":
+		fmt.Fprintf(c.out, "%s -> invalid IP address or CIDR (%s)\n", res.Input, res.Error)
+	case res.Reserved != "":
+		fmt.Fprintf(c.out, "%s -> %s (%s)\n", displayAddr(res), reservedNotice, res.Reserved)
+	case !res.Owned && res.ASN != "":
+		fmt.Fprintf(c.out, "%s -> not in meta data but within GitHub ASN space (%s)%s\n", displayAddr(res), res.ASN, c.ptrSuffix(res))
+	case !res.Owned:
+		fmt.Fprintf(c.out, "%s -> not owned by GitHub%s%s\n", displayAddr(res), c.disclaimerSuffix(), c.ptrSuffix(res))
+	default:
+		fmt.Fprintf(c.out, "%s -> owned by GitHub (%s)%s\n", displayAddr(res), strings.Join(res.Labels, ", "), c.ptrSuffix(res))
+		if c.detail {
+			for _, match := range res.Matches {
+				fmt.Fprintf(c.out, "  %s → %s\n", match.Label, strings.Join(match.Prefixes, ", "))
+			}
+		}
+	}
+}
+
+// emitJSON writes res to w as a single JSON line, restricted to c.fields when set.
+func (c *checker) emitJSON(w io.Writer, input string, res any) {
+	data, err := json.Marshal(c.selectedFields(input, res))
+	if err != nil {
+		fmt.Fprintf(w, "{\"input\":%q,\"error\":%q}\n", input, err.Error())
+		return
+	}
+	fmt.Fprintf(w, "%s\n", data)
+}
+
+// selectedFields restricts res to --fields, if set, answering a failure with an
+// error object for input.
+func (c *checker) selectedFields(input string, res any) any {
+	if len(c.fields) == 0 {
+		return res
+	}
+	selected, err := selectFields(res, c.fields)
+	if err != nil {
+		return map[string]string{"input": input, "error": err.Error()}
+	}
+	return selected
+}
+
+// lookupPTR resolves the reverse DNS names for addr. A missing record is not an error.
+func (c *checker) lookupPTR(addr netip.Addr) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	names, err := c.resolver.LookupAddr(ctx, addr.String())
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, ".")
+	}
+	return names, nil
+}
+
+// disclaimerSuffix returns the disclaimer in parentheses for a not-owned line, or
+// nothing with --no-disclaimer.
+func (c *checker) disclaimerSuffix() string {
+	if c.disclaimer == "" {
+		return ""
+	}
+	return " (" + c.disclaimer + ")"
+}
+
+// ptrSuffix returns the " [PTR: ...]" annotation for res, or "" when --ptr is off.
+func (c *checker) ptrSuffix(res addrResult) string {
+	switch {
+	case c.resolver == nil:
+		return ""
+	case res.ptrErr != nil:
+		return fmt.Sprintf(" [PTR lookup failed: %v]", res.ptrErr)
+	case len(res.PTR) == 0:
+		return " [PTR: none]"
+	default:
+		return " [PTR: " + strings.Join(res.PTR, ", ") + "]"
+	}
+}
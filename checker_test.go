@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+func TestEvaluateInputInvalidReasonCodes(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.format = "json"
+	for _, raw := range []string{"192.168.1.256", "not-an-ip", "10.0.0.0/40"} {
+		c.evaluateInput(raw)
+	}
+
+	var reasons []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var res addrResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		if res.Error == "" {
+			t.Fatalf("expected an error message, got %q", line)
+		}
+		reasons = append(reasons, string(res.Reason))
+	}
+	if got := strings.Join(reasons, ","); got != "bad_octet,not_an_ip,bad_cidr_bits" {
+		t.Fatalf("unexpected reason codes %q", got)
+	}
+}
+
+func TestEvaluateInputInvalidProseIsUnchanged(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.evaluateInput("not-an-ip")
+
+	if !strings.HasPrefix(out.String(), "not-an-ip -> invalid IP address or CIDR (") || strings.Contains(out.String(), "not_an_ip") {
+		t.Fatalf("unexpected prose output %q", out)
+	}
+}
+
+func TestEvaluateAddrDetailPairsLabelsWithPrefixes(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.detail = true
+	c.evaluateInput("192.30.252.0")
+
+	want := "192.30.252.0 -> owned by GitHub (api, hooks)\n" +
+		"  api → 192.30.252.0/24\n" +
+		"  hooks → 192.30.252.0/22\n"
+	if out.String() != want {
+		t.Fatalf("detail output = %q, want %q", out, want)
+	}
+}
+
+func TestEvaluateAddrDetailListsEveryPrefixPerLabel(t *testing.T) {
+	meta, err := githubmeta.Parse(strings.NewReader(`{"hooks": ["192.30.252.0/22", "192.30.252.0/23"], "api": ["192.30.252.0/24"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, out := newTestChecker(t)
+	c.meta = meta
+	c.detail = true
+	c.evaluateInput("192.30.252.0")
+
+	if !strings.Contains(out.String(), "  hooks → 192.30.252.0/22, 192.30.252.0/23\n") {
+		t.Fatalf("expected both hooks prefixes, got %q", out)
+	}
+}
+
+func TestEvaluateAddrASNFlagsAddressesMissingFromMeta(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.asn = []asnPrefix{{"AS64500", netip.MustParsePrefix("143.55.64.0/20")}}
+	c.evaluateInput("143.55.64.10")
+	c.evaluateInput("8.8.8.8")
+	c.evaluateInput("140.82.112.5")
+
+	got := out.String()
+	for _, want := range []string{
+		"143.55.64.10 -> not in meta data but within GitHub ASN space (AS64500)",
+		"8.8.8.8 -> not owned by GitHub (based on current meta data)",
+		"140.82.112.5 -> owned by GitHub (web)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output, got %q", want, got)
+		}
+	}
+}
+
+func TestEvaluateAddrExpandLabels(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.expandLabels = true
+	c.evaluateInput("192.30.252.0")
+
+	want := "192.30.252.0 -> owned by GitHub (api)\n192.30.252.0 -> owned by GitHub (hooks)\n"
+	if out.String() != want {
+		t.Fatalf("expanded output = %q, want %q", out, want)
+	}
+
+	c, out = newTestChecker(t)
+	c.expandLabels = true
+	c.format = "json"
+	c.evaluateInput("192.30.252.0")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one JSON record per label, got %q", out)
+	}
+	for i, want := range []struct{ label, prefix string }{{"api", "192.30.252.0/24"}, {"hooks", "192.30.252.0/22"}} {
+		var res addrResult
+		if err := json.Unmarshal([]byte(lines[i]), &res); err != nil {
+			t.Fatalf("decode record: %v", err)
+		}
+		if strings.Join(res.Labels, ",") != want.label || strings.Join(res.Prefixes, ",") != want.prefix {
+			t.Errorf("record %d = %+v, want label %s with prefix %s", i, res, want.label, want.prefix)
+		}
+	}
+}
+
+func TestEvaluateInputWarnReserved(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.warnReserved = true
+	for _, input := range []string{"192.168.1.1", "127.0.0.1", "10.0.0.0/8", "fe80::1", "192.30.252.44", "8.8.8.8"} {
+		c.evaluateInput(input)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"192.168.1.1 -> address is in private/reserved space; GitHub does not use this range (private)\n",
+		"127.0.0.1 -> address is in private/reserved space; GitHub does not use this range (loopback)\n",
+		"10.0.0.0/8 -> address is in private/reserved space; GitHub does not use this range (private)\n",
+		"fe80::1 -> address is in private/reserved space; GitHub does not use this range (link-local)\n",
+		"192.30.252.44 -> owned by GitHub (api, hooks)\n",
+		"8.8.8.8 -> not owned by GitHub (based on current meta data)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output, got %q", want, got)
+		}
+	}
+
+	c, out = newTestChecker(t)
+	c.evaluateInput("192.168.1.1")
+	if !strings.Contains(out.String(), "192.168.1.1 -> not owned by GitHub") {
+		t.Fatalf("without --warn-reserved the address is looked up as usual, got %q", out)
+	}
+}
+
+func TestEvaluateAddrCanonicalWarn(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.canonicalWarn = true
+	c.evaluateInput("2001:DB8:1:0::1")
+	c.evaluateInput("192.30.252.44")
+
+	want := "note: 2001:DB8:1:0::1 is not in canonical form (2001:db8:1::1)\n" +
+		"2001:db8:1::1 -> owned by GitHub (hooks)\n" +
+		"192.30.252.44 -> owned by GitHub (api, hooks)\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	c, out = newTestChecker(t)
+	c.canonicalWarn = true
+	c.format = "json"
+	c.evaluateInput("2001:db8:1:0:0:0:0:1")
+	var res addrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if !res.NonCanonical || res.Address != "2001:db8:1::1" || !res.Owned {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestCompareDisjointLabels(t *testing.T) {
+	c, out := newTestChecker(t)
+	if err := c.compare("192.30.252.42", "140.82.112.5"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "192.30.252.42 and 140.82.112.5 share no labels\n" +
+		"  only 192.30.252.42: api, hooks\n" +
+		"  only 140.82.112.5: web\n"
+	if out.String() != want {
+		t.Fatalf("compare output = %q, want %q", out, want)
+	}
+}
+
+func TestCompareSharedLabel(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.format = "json"
+	if err := c.compare("192.30.252.42", "192.30.253.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var res compareResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if strings.Join(res.Shared, ",") != "hooks" || strings.Join(res.OnlyA, ",") != "api" || len(res.OnlyB) != 0 {
+		t.Fatalf("unexpected comparison %+v", res)
+	}
+}
+
+func TestCompareNotOwned(t *testing.T) {
+	c, out := newTestChecker(t)
+	if err := c.compare("140.82.112.5", "8.8.8.8"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "  8.8.8.8: not owned by GitHub (") || !strings.Contains(out.String(), "  only 140.82.112.5: web\n") {
+		t.Fatalf("unexpected compare output %q", out)
+	}
+	if err := c.compare("140.82.112.5", "not-an-ip"); err == nil {
+		t.Fatal("expected an error for an invalid address")
+	}
+}
+
+func TestNumericOutput(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.numeric = true
+	c.evaluateInput("192.30.252.42")
+	c.evaluateInput("2001:db8:1::1")
+
+	want := "192.30.252.42 (3223256106) -> owned by GitHub (api, hooks)\n" +
+		"2001:db8:1::1 (42540766411283801782723599580828532737) -> owned by GitHub (hooks)\n"
+	if out.String() != want {
+		t.Fatalf("numeric output = %q, want %q", out, want)
+	}
+
+	out.Reset()
+	c.format = "json"
+	c.evaluateInput("192.30.252.42")
+	if !strings.Contains(out.String(), `"numeric":3223256106`) {
+		t.Fatalf("expected the numeric field in %q", out)
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// defaultCIDRLimit is the largest CIDR (in addresses) evaluated unless --limit says otherwise.
+const defaultCIDRLimit = 4096
+
+// cidrResult summarises the evaluation of every address in a prefix.
+type cidrResult struct {
+	Input  string   `json:"input"`
+	Prefix string   `json:"prefix"`
+	Total  *big.Int `json:"total"`
+	// OwnedCount is the number of addresses matching at least one label.
+	OwnedCount int `json:"owned_count"`
+	// LabelSets counts addresses by the comma-joined set of labels they matched.
+	LabelSets map[string]int `json:"label_sets"`
+	// DistinctLabels is the number of labels matched by any address, the size of
+	// the union of LabelSets.
+	DistinctLabels int    `json:"distinct_labels"`
+	Truncated      bool   `json:"truncated,omitempty"`
+	Error          string `json:"error,omitempty"`
+	// SuggestedMaxPrefix is the shortest prefix length that fits under --limit,
+	// set when the CIDR was too large to evaluate.
+	SuggestedMaxPrefix int `json:"suggested_max_prefix,omitempty"`
+	// Sampled is the sample size when an over-limit CIDR was estimated with --sample;
+	// OwnedCount and LabelSets then count sampled addresses.
+	Sampled               int      `json:"sampled,omitempty"`
+	EstimatedOwnedPercent *float64 `json:"estimated_owned_percent,omitempty"`
+	// Reserved names the special-purpose scope of a prefix skipped by --warn-reserved.
+	Reserved string `json:"reserved,omitempty"`
+	// UnmatchedLabels lists the labels in the meta data that no address of the prefix
+	// matched, e.g. everything but "pages" for a pages-only block.
+	UnmatchedLabels []string `json:"unmatched_labels,omitempty"`
+	// FirstOwned, LastOwned and OwnershipChanges locate where GitHub's space starts,
+	// ends and flips inside the prefix; set with --boundaries.
+	FirstOwned       string            `json:"first_owned,omitempty"`
+	LastOwned        string            `json:"last_owned,omitempty"`
+	OwnershipChanges []ownershipChange `json:"ownership_changes,omitempty"`
+	// Relations compares the prefix with each published prefix it overlaps; set with
+	// --relation, also for prefixes too large to evaluate.
+	Relations []prefixRelation `json:"relations,omitempty"`
+	// Unowned lists the fewest CIDRs covering the addresses of the prefix that no
+	// entry covers; set with --show-unowned, also for prefixes too large to evaluate.
+	Unowned []string `json:"unowned,omitempty"`
+	// Status classifies the result in one word for --status; see cidrStatus.
+	Status string `json:"status,omitempty"`
+}
+
+// CIDR statuses reported by --status.
+const (
+	statusFull     = "FULL"
+	statusPartial  = "PARTIAL"
+	statusNone     = "NONE"
+	statusTooLarge = "TOOLARGE"
+	statusInvalid  = "INVALID"
+)
+
+// cidrStatus classifies res: FULL, PARTIAL or NONE by how many of its addresses
+// GitHub owns (of the sample, when estimated), TOOLARGE when it was refused for
+// exceeding --limit and INVALID for any other error. Reserved prefixes are NONE.
+func (c *checker) cidrStatus(res cidrResult) string {
+	switch {
+	case res.Error != "" && res.Total != nil && res.Total.Cmp(big.NewInt(int64(c.limit))) > 0:
+		return statusTooLarge
+	case res.Error != "":
+		return statusInvalid
+	case res.OwnedCount == 0:
+		return statusNone
+	}
+	checked := res.Total
+	if res.Sampled > 0 {
+		checked = big.NewInt(int64(res.Sampled))
+	}
+	if checked.Cmp(big.NewInt(int64(res.OwnedCount))) > 0 {
+		return statusPartial
+	}
+	return statusFull
+}
+
+// prefixRelation is one entry of cidrResult.Relations: the input is equal to, a
+// subset of or a superset of Prefix, published under Label.
+type prefixRelation struct {
+	Label    string `json:"label"`
+	Prefix   string `json:"prefix"`
+	Relation string `json:"relation"`
+}
+
+// ownershipChange is a pair of consecutive addresses whose ownership differs.
+type ownershipChange struct {
+	Last  string `json:"last"`
+	First string `json:"first"`
+	// Owned reports whether First, and so everything from it to the next change, is owned.
+	Owned bool `json:"owned"`
+}
+
+// boundaryTracker implements --boundaries by watching the addresses of a prefix go
+// by in order.
+type boundaryTracker struct {
+	prev        netip.Addr
+	prevOwned   bool
+	first, last netip.Addr
+	changes     []ownershipChange
+}
+
+func (b *boundaryTracker) visit(addr netip.Addr, labels []string) {
+	owned := len(labels) > 0
+	if b.prev.IsValid() && owned != b.prevOwned {
+		b.changes = append(b.changes, ownershipChange{Last: b.prev.String(), First: addr.String(), Owned: owned})
+	}
+	if owned {
+		if !b.first.IsValid() {
+			b.first = addr
+		}
+		b.last = addr
+	}
+	b.prev, b.prevOwned = addr, owned
+}
+
+// record copies what the tracker saw into res.
+func (b *boundaryTracker) record(res *cidrResult) {
+	if b.first.IsValid() {
+		res.FirstOwned = b.first.String()
+		res.LastOwned = b.last.String()
+	}
+	res.OwnershipChanges = b.changes
+}
+
+// evaluateCIDR looks up every address in prefix and prints an ownership summary,
+// preceded by per-address rows when --per-address is set. Under --status the rows
+// and notes follow the summary instead, keeping the token on the first line.
+func (c *checker) evaluateCIDR(raw string, prefix netip.Prefix) {
+	if c.status && c.prose() {
+		var held bytes.Buffer
+		out := c.out
+		c.out, c.summaryOut = &held, out
+		defer func() {
+			c.out, c.summaryOut = out, nil
+			held.WriteTo(out)
+		}()
+	}
+	// A prefix typed with host bits set (192.30.252.42/22) means its whole network,
+	// so iteration must start at the network address rather than the typed one.
+	if masked := prefix.Masked(); masked != prefix {
+		if c.prose() {
+			fmt.Fprintf(c.out, "treating %s as %s\n", prefix, masked)
+		}
+		prefix = masked
+	}
+	if c.warnReserved {
+		if kind := reservedPrefixKind(prefix); kind != "" {
+			c.emitCIDR(cidrResult{Input: raw, Prefix: prefix.String(), Total: githubmeta.PrefixSize(prefix), LabelSets: map[string]int{}, Reserved: kind})
+			return
+		}
+	}
+
+	var rows, omitted int
+	var bounds *boundaryTracker
+	if c.boundaries {
+		bounds = &boundaryTracker{}
+	}
+	var visit func(netip.Addr, []string)
+	if c.perAddress || bounds != nil {
+		visit = func(addr netip.Addr, labels []string) {
+			if bounds != nil {
+				bounds.visit(addr, labels)
+			}
+			if !c.perAddress {
+				return
+			}
+			res := c.lookup(addr.String(), addr)
+			// Rows hidden by --only-misses or --only-matches use none of the budget.
+			if c.maxResults > 0 && rows >= c.maxResults {
+				if !c.hidden(res) {
+					omitted++
+				}
+				return
+			}
+			if c.emitRow(res) {
+				rows++
+			}
+		}
+	}
+
+	summary, err := c.meta.EvaluatePrefixFunc(prefix, c.limit, visit)
+	res := cidrResult{
+		Input:      raw,
+		Prefix:     summary.Prefix.String(),
+		Total:      summary.Total,
+		OwnedCount: summary.Owned,
+		LabelSets:  summary.LabelSets,
+	}
+	if c.relation {
+		for _, rel := range c.meta.Relations(prefix) {
+			res.Relations = append(res.Relations, prefixRelation{Label: rel.Entry.Label, Prefix: rel.Entry.Prefix.String(), Relation: string(rel.Relation)})
+		}
+	}
+	if c.showUnowned {
+		// ComplementWithin works on prefixes, so gaps of any size cost a line each.
+		for _, gap := range c.meta.ComplementWithin(prefix) {
+			res.Unowned = append(res.Unowned, gap.String())
+		}
+	}
+	if err != nil {
+		var tooLarge *githubmeta.RangeTooLargeError
+		if errors.As(err, &tooLarge) && c.sample > 0 {
+			c.sampleCIDR(raw, prefix)
+			return
+		}
+		res.Error = err.Error()
+		if tooLarge != nil {
+			res.SuggestedMaxPrefix = githubmeta.FittingPrefixBits(tooLarge.Prefix, tooLarge.Limit)
+		}
+		c.emitCIDR(res)
+		return
+	}
+
+	res.UnmatchedLabels = unmatchedLabels(c.meta.Labels(), res.LabelSets)
+	if bounds != nil {
+		bounds.record(&res)
+	}
+	c.checked += res.Total.Int64()
+	if c.aggregate {
+		c.aggregated = append(c.aggregated, prefix)
+	}
+	if omitted > 0 {
+		res.Truncated = true
+		if c.prose() {
+			fmt.Fprintf(c.out, "… (truncated, %d more)\n", omitted)
+		}
+	}
+	c.emitCIDR(res)
+}
+
+// sampleCIDR implements --sample: it estimates the ownership of an over-limit prefix
+// from c.sample random addresses.
+func (c *checker) sampleCIDR(raw string, prefix netip.Prefix) {
+	sample, err := c.meta.SamplePrefix(prefix, c.sample, c.rnd)
+	res := cidrResult{
+		Input:      raw,
+		Prefix:     prefix.String(),
+		Total:      sample.Total,
+		OwnedCount: sample.Owned,
+		LabelSets:  sample.LabelSets,
+		Sampled:    sample.Sampled,
+	}
+	c.checked += int64(sample.Sampled)
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		percent := sample.OwnedPercent()
+		res.EstimatedOwnedPercent = &percent
+	}
+	c.emitCIDR(res)
+}
+
+func (c *checker) emitCIDR(res cidrResult) {
+	res.DistinctLabels = len(distinctLabels(res.LabelSets))
+	if c.status {
+		res.Status = c.cidrStatus(res)
+	}
+	c.recordCIDR(res)
+	c.tally.addCIDR(res)
+	if c.jsonOut != nil {
+		c.emitJSON(c.jsonOut, res.Input, res)
+	}
+	if c.collect != nil {
+		c.collect(res)
+		return
+	}
+	if c.tmpl != nil {
+		c.emitTemplate(res)
+		return
+	}
+	if c.format == "json" {
+		c.emitJSON(c.out, res.Input, res)
+		return
+	}
+	if c.labelsOnly {
+		c.writeLabels(distinctLabels(res.LabelSets))
+		return
+	}
+
+	if c.summaryOut != nil {
+		held := c.out
+		c.out = c.summaryOut
+		defer func() { c.out = held }()
+	}
+	// The --status token leads the first line so that it can be matched with ^.
+	lead := ""
+	if res.Status != "" {
+		lead = res.Status + " "
+	}
+	if res.Error != "" {
+		fmt.Fprintf(c.out, "%s%s -> %s\n", lead, res.Input, res.Error)
+		c.printRelations(res)
+		c.printUnowned(res)
+		return
+	}
+	if res.Reserved != "" {
+		fmt.Fprintf(c.out, "%s%s -> %s (%s)\n", lead, res.Prefix, reservedNotice, res.Reserved)
+		return
+	}
+	if res.Sampled > 0 {
+		fmt.Fprintf(c.out, "%s%s -> an estimated %.1f%% of %s addresses owned by GitHub (sample of %d)\n",
+			lead, res.Prefix, *res.EstimatedOwnedPercent, res.Total, res.Sampled)
+		for _, set := range sortedLabelSets(res.LabelSets) {
+			fmt.Fprintf(c.out, "  %s: %d sampled addresses\n", set, res.LabelSets[set])
+		}
+		if res.DistinctLabels > 0 {
+			fmt.Fprintf(c.out, "  distinct labels: %d\n", res.DistinctLabels)
+		}
+		return
+	}
+	fmt.Fprintf(c.out, "%s%s -> %d of %s addresses owned by GitHub\n", lead, res.Prefix, res.OwnedCount, res.Total)
+	for _, set := range sortedLabelSets(res.LabelSets) {
+		fmt.Fprintf(c.out, "  %s: %d addresses\n", set, res.LabelSets[set])
+	}
+	if res.DistinctLabels > 0 {
+		fmt.Fprintf(c.out, "  distinct labels: %d\n", res.DistinctLabels)
+	}
+	c.printRelations(res)
+	c.printUnowned(res)
+	if res.FirstOwned != "" {
+		fmt.Fprintf(c.out, "  owned from %s to %s\n", res.FirstOwned, res.LastOwned)
+	}
+	for _, change := range res.OwnershipChanges {
+		fmt.Fprintf(c.out, "  ownership changes: %s %s -> %s %s\n", change.Last, ownedWord(!change.Owned), change.First, ownedWord(change.Owned))
+	}
+	if res.OwnedCount == 0 {
+		if c.disclaimer != "" {
+			fmt.Fprintf(c.out, "  (%s)\n", c.disclaimer)
+		}
+	} else if len(res.UnmatchedLabels) > 0 {
+		fmt.Fprintf(c.out, "  unmatched labels: %s\n", strings.Join(res.UnmatchedLabels, ", "))
+	}
+}
+
+// printRelations writes the --relation lines of res, e.g. "subset of 192.30.252.0/22 (hooks)".
+func (c *checker) printRelations(res cidrResult) {
+	for _, rel := range res.Relations {
+		fmt.Fprintf(c.out, "  %s %s (%s)\n", relationWords[rel.Relation], rel.Prefix, rel.Label)
+	}
+}
+
+// printUnowned writes the --show-unowned lines of res, e.g. "not owned: 185.199.109.0/24".
+func (c *checker) printUnowned(res cidrResult) {
+	for _, gap := range res.Unowned {
+		fmt.Fprintf(c.out, "  not owned: %s\n", gap)
+	}
+}
+
+// relationWords spells out a githubmeta.Relation for text output.
+var relationWords = map[string]string{
+	string(githubmeta.RelationEqual):    "equal to",
+	string(githubmeta.RelationSubset):   "subset of",
+	string(githubmeta.RelationSuperset): "superset of",
+}
+
+// ownedWord describes an address's ownership in --boundaries output.
+func ownedWord(owned bool) string {
+	if owned {
+		return "owned"
+	}
+	return "not owned"
+}
+
+// recordCIDR adds the outcome of a CIDR to the --bitmask-exit bits: owned when any
+// address matched, not owned when any did not, and invalid when it was refused.
+func (c *checker) recordCIDR(res cidrResult) {
+	if res.Error != "" {
+		c.outcomes |= exitInvalid
+		return
+	}
+	if res.OwnedCount > 0 {
+		c.outcomes |= exitOwned
+	}
+	checked := res.Total
+	if res.Sampled > 0 {
+		checked = big.NewInt(int64(res.Sampled))
+	}
+	if checked.Cmp(big.NewInt(int64(res.OwnedCount))) > 0 {
+		c.outcomes |= exitNotOwned
+	}
+}
+
+// sortedLabelSets orders label sets by descending address count, then name.
+func sortedLabelSets(sets map[string]int) []string {
+	keys := make([]string, 0, len(sets))
+	for key := range sets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sets[keys[i]] != sets[keys[j]] {
+			return sets[keys[i]] > sets[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// unmatchedLabels returns the labels of all that appear in none of the label sets.
+func unmatchedLabels(all []string, sets map[string]int) []string {
+	matched := distinctLabels(sets)
+	unmatched := []string{}
+	for _, label := range all {
+		if !slices.Contains(matched, label) {
+			unmatched = append(unmatched, label)
+		}
+	}
+	return unmatched
+}
+
+// distinctLabels returns the sorted labels appearing in any of the label sets.
+func distinctLabels(sets map[string]int) []string {
+	var labels []string
+	for set := range sets {
+		for _, label := range strings.Split(set, ", ") {
+			if !slices.Contains(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+func TestEvaluateCIDRSummary(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.evaluateInput("192.30.252.0/23")
+
+	got := out.String()
+	for _, want := range []string{
+		"192.30.252.0/23 -> 512 of 512 addresses owned by GitHub",
+		"  api, hooks: 256 addresses",
+		"  hooks: 256 addresses",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output, got %q", want, got)
+		}
+	}
+}
+
+func TestEvaluateCIDRTooLarge(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.evaluateInput("140.82.0.0/16")
+
+	if !strings.Contains(out.String(), "CIDR too large to evaluate (65536 addresses exceeds the limit of 4096); a /20 would fit under the 4096 limit") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestEvaluateCIDRTooLargeSuggestsPrefixJSON(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.format = "json"
+	c.evaluateInput("140.82.0.0/16")
+	c.evaluateInput("2001:db8:1::/48")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two results, got %q", out)
+	}
+	for i, want := range []int{20, 116} {
+		var res cidrResult
+		if err := json.Unmarshal([]byte(lines[i]), &res); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		if res.Error == "" || res.SuggestedMaxPrefix != want {
+			t.Fatalf("expected suggested_max_prefix %d, got %+v", want, res)
+		}
+	}
+}
+
+func TestEvaluateCIDRMaxResults(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.perAddress = true
+	c.maxResults = 4
+	c.evaluateInput("192.30.252.0/28")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var rows int
+	for _, line := range lines {
+		if strings.Contains(line, " -> owned by GitHub") {
+			rows++
+		}
+	}
+	if rows != 4 {
+		t.Fatalf("expected 4 per-address rows, got %d: %q", rows, out)
+	}
+	if !strings.Contains(out.String(), "… (truncated, 12 more)") {
+		t.Fatalf("expected truncation notice, got %q", out)
+	}
+	if !strings.Contains(out.String(), "16 of 16 addresses owned by GitHub") {
+		t.Fatalf("summary should cover the full range, got %q", out)
+	}
+}
+
+func TestEvaluateCIDRMaxResultsCountsOnlyShownRows(t *testing.T) {
+	c, out := newTestChecker(t)
+	meta, err := githubmeta.Parse(strings.NewReader(`{"pages": ["185.199.108.0/25"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.meta = meta
+	c.perAddress = true
+	c.maxResults = 4
+	c.only = "misses"
+	c.evaluateInput("185.199.108.0/24")
+
+	// The 128 owned addresses come first and are hidden, so they must not use up
+	// the budget or be counted as truncated.
+	want := "185.199.108.128 -> not owned by GitHub (based on current meta data)\n" +
+		"185.199.108.129 -> not owned by GitHub (based on current meta data)\n" +
+		"185.199.108.130 -> not owned by GitHub (based on current meta data)\n" +
+		"185.199.108.131 -> not owned by GitHub (based on current meta data)\n" +
+		"… (truncated, 124 more)\n"
+	if !strings.HasPrefix(out.String(), want) {
+		t.Fatalf("got %q, want it to start with %q", out, want)
+	}
+}
+
+func TestEvaluateCIDRMaxResultsJSON(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.format = "json"
+	c.perAddress = true
+	c.maxResults = 4
+	c.evaluateInput("192.30.252.0/28")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 4 rows and a summary, got %d lines: %q", len(lines), out)
+	}
+	var summary cidrResult
+	if err := json.Unmarshal([]byte(lines[4]), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if !summary.Truncated || summary.OwnedCount != 16 {
+		t.Fatalf("expected truncated summary covering 16 addresses, got %+v", summary)
+	}
+}
+
+func TestEvaluateCIDRMasksHostBits(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.perAddress = true
+	c.evaluateInput("192.30.252.42/22")
+
+	lines := strings.Split(out.String(), "\n")
+	if lines[0] != "treating 192.30.252.42/22 as 192.30.252.0/22" {
+		t.Fatalf("expected normalization note first, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "192.30.252.0 -> ") {
+		t.Fatalf("expected iteration to start at the network address, got %q", lines[1])
+	}
+	if !strings.Contains(out.String(), "192.30.252.0/22 -> 1024 of 1024 addresses owned by GitHub") {
+		t.Fatalf("expected all 1024 addresses to be evaluated, got summary %q", lines[len(lines)-4:])
+	}
+	if strings.Count(out.String(), " -> owned by GitHub") != 1024 {
+		t.Fatalf("expected 1024 per-address rows")
+	}
+}
+
+func TestEvaluateCIDRSampleEstimatesOverLimit(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.limit = 256
+	c.sample = 50
+	c.rnd = rand.New(rand.NewSource(1))
+	c.evaluateInput("192.30.254.0/23")
+
+	want := "192.30.254.0/23 -> an estimated 100.0% of 512 addresses owned by GitHub (sample of 50)\n  hooks: 50 sampled addresses\n  distinct labels: 1\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvaluateCIDRSampleIsDeterministicWithSeed(t *testing.T) {
+	run := func() string {
+		c, out := newTestChecker(t)
+		c.format = "json"
+		c.sample = 500
+		c.rnd = rand.New(rand.NewSource(99))
+		c.evaluateInput("192.30.0.0/16")
+		return out.String()
+	}
+	first := run()
+	if second := run(); first != second {
+		t.Fatalf("same seed gave different results:\n%s\n%s", first, second)
+	}
+
+	var res cidrResult
+	if err := json.Unmarshal([]byte(first), &res); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	// hooks covers 1024 of the 65536 addresses, about 1.6%.
+	if res.Sampled != 500 || res.EstimatedOwnedPercent == nil || *res.EstimatedOwnedPercent > 10 {
+		t.Fatalf("unexpected estimate %+v", res)
+	}
+}
+
+func TestAggregateCountsOverlappingCIDRsOnce(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.aggregate = true
+	c.evaluateInput("192.30.252.0/23")
+	c.evaluateInput("192.30.252.0/24")
+	c.evaluateInput("140.82.0.0/16") // too large, so left out of the aggregate
+	out.Reset()
+	c.emitAggregate()
+
+	want := "Aggregate: 512 of 512 addresses owned by GitHub across 2 CIDRs (256 overlapping addresses counted once)\n" +
+		"  api, hooks: 256 addresses\n" +
+		"  hooks: 256 addresses\n" +
+		"  matched prefixes: 192.30.252.0/22, 192.30.252.0/24\n"
+	if out.String() != want {
+		t.Fatalf("aggregate output = %q, want %q", out, want)
+	}
+
+	out.Reset()
+	c.format = "json"
+	c.emitAggregate()
+	var got struct {
+		Aggregate aggregateResult `json:"aggregate"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if got.Aggregate.Total.Int64() != 512 || got.Aggregate.OverlapCount.Int64() != 256 || strings.Join(got.Aggregate.Prefixes, ",") != "192.30.252.0/23" {
+		t.Fatalf("unexpected aggregate %+v", got.Aggregate)
+	}
+}
+
+func TestEvaluateCIDRListsUnmatchedLabels(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.evaluateInput("192.30.253.0/24")
+
+	want := "192.30.253.0/24 -> 256 of 256 addresses owned by GitHub\n" +
+		"  hooks: 256 addresses\n" +
+		"  distinct labels: 1\n" +
+		"  unmatched labels: api, pages, web\n"
+	if out.String() != want {
+		t.Fatalf("CIDR output = %q, want %q", out, want)
+	}
+
+	out.Reset()
+	c.format = "json"
+	c.evaluateInput("192.30.253.0/24")
+	var res cidrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if strings.Join(res.UnmatchedLabels, ",") != "api,pages,web" {
+		t.Fatalf("unexpected unmatched labels %q", res.UnmatchedLabels)
+	}
+}
+
+func TestEvaluateCIDRBoundaries(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.boundaries = true
+	c.limit = 8192
+	// The smallest CIDR spanning the upper edge of pages' 185.199.108.0/22.
+	c.evaluateInput("185.199.96.0/19")
+
+	for _, want := range []string{
+		"185.199.96.0/19 -> 1024 of 8192 addresses owned by GitHub\n",
+		"  owned from 185.199.108.0 to 185.199.111.255\n",
+		"  ownership changes: 185.199.107.255 not owned -> 185.199.108.0 owned\n",
+		"  ownership changes: 185.199.111.255 owned -> 185.199.112.0 not owned\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+
+	out.Reset()
+	c.format = "json"
+	c.evaluateInput("185.199.96.0/19")
+	var res cidrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	want := []ownershipChange{
+		{Last: "185.199.107.255", First: "185.199.108.0", Owned: true},
+		{Last: "185.199.111.255", First: "185.199.112.0", Owned: false},
+	}
+	if !reflect.DeepEqual(res.OwnershipChanges, want) || res.LastOwned != "185.199.111.255" {
+		t.Fatalf("unexpected boundaries %+v", res)
+	}
+}
+
+func TestEvaluateCIDRRelation(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.relation = true
+	c.evaluateInput("192.30.253.0/24")
+	c.evaluateInput("192.30.248.0/21")
+
+	for _, want := range []string{
+		"192.30.253.0/24 -> 256 of 256 addresses owned by GitHub\n  hooks: 256 addresses\n  distinct labels: 1\n  subset of 192.30.252.0/22 (hooks)\n",
+		"  superset of 192.30.252.0/22 (hooks)\n",
+		"  superset of 192.30.252.0/24 (api)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+
+	out.Reset()
+	c.format = "json"
+	c.evaluateInput("192.30.253.0/24")
+	var res cidrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	want := []prefixRelation{{Label: "hooks", Prefix: "192.30.252.0/22", Relation: "subset_of"}}
+	if !reflect.DeepEqual(res.Relations, want) {
+		t.Fatalf("relations = %+v, want %+v", res.Relations, want)
+	}
+}
+
+func TestEvaluateCIDRStatusToken(t *testing.T) {
+	cases := map[string]string{
+		"192.30.252.0/24": "FULL 192.30.252.0/24 -> 256 of 256 addresses owned by GitHub\n",
+		"192.30.248.0/21": "PARTIAL 192.30.248.0/21 -> 1024 of 2048 addresses owned by GitHub\n",
+		"192.30.250.0/23": "NONE 192.30.250.0/23 -> 0 of 512 addresses owned by GitHub\n",
+		"10.0.0.0/8":      "TOOLARGE 10.0.0.0/8 -> CIDR too large to evaluate",
+		"1.2.3.0/33":      "INVALID 1.2.3.0/33 -> invalid IP address or CIDR",
+	}
+	for input, want := range cases {
+		c, out := newTestChecker(t)
+		c.status = true
+		c.evaluateInput(input)
+		if !strings.HasPrefix(out.String(), want) {
+			t.Errorf("%s: got %q, want it to start with %q", input, out, want)
+		}
+	}
+
+	c, out := newTestChecker(t)
+	c.status = true
+	c.limit = 256
+	c.format = "json"
+	c.evaluateInput("192.30.252.0/23")
+	var res cidrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if res.Status != "TOOLARGE" {
+		t.Fatalf("JSON status = %q, want TOOLARGE", res.Status)
+	}
+}
+
+func TestEvaluateCIDRStatusTokenLeadsFirstLine(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.status = true
+	c.evaluateInput("192.30.252.42/22")
+	lines := strings.Split(out.String(), "\n")
+	if !strings.HasPrefix(lines[0], "FULL 192.30.252.0/22 -> ") {
+		t.Fatalf("first line %q should start with the token; output %q", lines[0], out)
+	}
+	if !strings.Contains(out.String(), "treating 192.30.252.42/22 as 192.30.252.0/22\n") {
+		t.Fatalf("the host-bit note should still be printed, got %q", out)
+	}
+
+	c, out = newTestChecker(t)
+	c.status = true
+	c.perAddress = true
+	c.evaluateInput("192.30.252.0/30")
+	lines = strings.Split(out.String(), "\n")
+	if !strings.HasPrefix(lines[0], "FULL 192.30.252.0/30 -> 4 of 4 addresses owned by GitHub") {
+		t.Fatalf("first line %q should start with the token; output %q", lines[0], out)
+	}
+	if !strings.Contains(out.String(), "\n192.30.252.3 -> owned by GitHub") {
+		t.Fatalf("per-address rows should follow the summary, got %q", out)
+	}
+}
+
+func TestEvaluateCIDRDistinctLabels(t *testing.T) {
+	c, out := newTestChecker(t)
+	c.evaluateInput("192.30.252.0/23")
+	if !strings.Contains(out.String(), "  distinct labels: 2\n") {
+		t.Fatalf("expected 2 distinct labels for a block touching hooks and api, got %q", out)
+	}
+
+	c, out = newTestChecker(t)
+	c.format = "json"
+	c.evaluateInput("192.30.252.0/23")
+	var res cidrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if res.DistinctLabels != 2 || !strings.Contains(out.String(), `"distinct_labels":2`) {
+		t.Fatalf("distinct_labels = %d in %s, want 2", res.DistinctLabels, out)
+	}
+}
+
+func TestEvaluateCIDRShowUnowned(t *testing.T) {
+	c, out := newTestChecker(t)
+	meta, err := githubmeta.Parse(strings.NewReader(`{"pages": ["185.199.108.0/24"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.meta = meta
+	c.showUnowned = true
+	c.evaluateInput("185.199.108.0/23")
+
+	want := "185.199.108.0/23 -> 256 of 512 addresses owned by GitHub\n  pages: 256 addresses\n  distinct labels: 1\n  not owned: 185.199.109.0/24\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+
+	// The gaps are computed from prefixes, so they are listed even over --limit.
+	out.Reset()
+	c.format = "json"
+	c.evaluateInput("185.199.0.0/16")
+	var res cidrResult
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if res.Error == "" || len(res.Unowned) != 8 || res.Unowned[0] != "185.199.0.0/18" || res.Unowned[7] != "185.199.128.0/17" {
+		t.Fatalf("unexpected over-limit result %+v", res)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// compareResult reports which labels two addresses have in common (--compare).
+type compareResult struct {
+	A      string   `json:"a"`
+	B      string   `json:"b"`
+	Shared []string `json:"shared"`
+	OnlyA  []string `json:"only_a"`
+	OnlyB  []string `json:"only_b"`
+}
+
+// compare looks up both addresses and prints the shared and distinct labels.
+func (c *checker) compare(rawA, rawB string) error {
+	a, err := netip.ParseAddr(rawA)
+	if err != nil {
+		return fmt.Errorf("invalid IP address %q: %w", rawA, err)
+	}
+	b, err := netip.ParseAddr(rawB)
+	if err != nil {
+		return fmt.Errorf("invalid IP address %q: %w", rawB, err)
+	}
+	labelsA, labelsB := c.meta.Lookup(a), c.meta.Lookup(b)
+
+	res := compareResult{A: a.String(), B: b.String(), Shared: []string{}, OnlyA: []string{}, OnlyB: []string{}}
+	for _, label := range labelsA {
+		if slices.Contains(labelsB, label) {
+			res.Shared = append(res.Shared, label)
+		} else {
+			res.OnlyA = append(res.OnlyA, label)
+		}
+	}
+	for _, label := range labelsB {
+		if !slices.Contains(labelsA, label) {
+			res.OnlyB = append(res.OnlyB, label)
+		}
+	}
+
+	if c.jsonOut != nil {
+		c.emitJSON(c.jsonOut, rawA, res)
+	}
+	if c.format == "json" {
+		c.emitJSON(c.out, rawA, res)
+		return nil
+	}
+	if len(res.Shared) == 0 {
+		fmt.Fprintf(c.out, "%s and %s share no labels\n", res.A, res.B)
+	} else {
+		fmt.Fprintf(c.out, "%s and %s share: %s\n", res.A, res.B, strings.Join(res.Shared, ", "))
+	}
+	for _, side := range []struct {
+		addr   string
+		labels []string
+		only   []string
+	}{{res.A, labelsA, res.OnlyA}, {res.B, labelsB, res.OnlyB}} {
+		switch {
+		case len(side.labels) == 0:
+			fmt.Fprintf(c.out, "  %s: not owned by GitHub%s\n", side.addr, c.disclaimerSuffix())
+		case len(side.only) == 0:
+			fmt.Fprintf(c.out, "  only %s: none\n", side.addr)
+		default:
+			fmt.Fprintf(c.out, "  only %s: %s\n", side.addr, strings.Join(side.only, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// configKeys are the settings a --config file may hold. Every key except token
+// names the flag it provides a default for; token has no flag so that it stays out
+// of shell history and process listings.
+var configKeys = []string{"cache-dir", "endpoint", "labels", "proxy", "timeout", "token"}
+
+// loadConfig reads a --config file: either a JSON object or key=value lines, where
+// blank lines and lines starting with # are ignored. In JSON, labels may also be an
+// array of strings. Unknown keys are an error so that typos are not silently ignored.
+func loadConfig(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]string{}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc map[string]any
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		for key, value := range doc {
+			s, err := configString(key, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			settings[key] = s
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(raw))
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(text, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: expected key=value", path, line)
+			}
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+
+	var unknown []string
+	for key := range settings {
+		if !slices.Contains(configKeys, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown setting(s) %s (expected %s)", path, strings.Join(unknown, ", "), strings.Join(configKeys, ", "))
+	}
+	return settings, nil
+}
+
+// configString converts a JSON config value to its flag form.
+func configString(key string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []any:
+		if key != "labels" {
+			break
+		}
+		labels := make([]string, len(v))
+		for i, label := range v {
+			s, ok := label.(string)
+			if !ok {
+				return "", fmt.Errorf("labels must be strings, got %v", label)
+			}
+			labels[i] = s
+		}
+		return strings.Join(labels, ","), nil
+	}
+	return "", fmt.Errorf("%s must be a string, got %v", key, value)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// runDiff implements the diff subcommand: "diff OLD.json NEW.json" compares two
+// snapshots, and "diff --watch INTERVAL" compares the live ranges over time.
+func (a *app) runDiff(ctx context.Context, args []string) int {
+	var src sourceOptions
+	fs := newFlagSet("diff", a.stderr)
+	src.addFlags(fs)
+	interval := fs.Duration("watch", 0, "re-fetch every INTERVAL (e.g. 10m) and print a diff whenever the ranges change")
+	checkUpdate := fs.Bool("check-update", false, "ask whether the ranges changed since the cached copy, without downloading them; exits 3 when they did")
+	verifyCache := fs.Bool("verify-cache", false, "download the ranges afresh and compare them with the cached copy, leaving the cache alone; exits 3 when they differ")
+	if err := fs.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if *checkUpdate || *verifyCache {
+		if *checkUpdate && *verifyCache {
+			return usageExitCode(usageError(fs, "--check-update and --verify-cache cannot be combined"))
+		}
+		if *interval != 0 || fs.NArg() > 0 || src.snapshot() {
+			return usageExitCode(usageError(fs, "--check-update and --verify-cache cannot be combined with --watch, --as-of, --archive-dir, --index or file arguments"))
+		}
+		if err := src.parsed(fs); err != nil {
+			return usageExitCode(err)
+		}
+		if *verifyCache {
+			return a.verifyCache(ctx, src)
+		}
+		return a.checkUpdate(ctx, src)
+	}
+	if *interval < 0 {
+		return usageExitCode(usageError(fs, "--watch interval must be positive"))
+	}
+	if *interval > 0 && src.snapshot() {
+		return usageExitCode(usageError(fs, "--watch cannot be combined with --as-of, --archive-dir or --index"))
+	}
+	if *interval == 0 && fs.NArg() != 2 {
+		return usageExitCode(usageError(fs, "diff expects two meta.json files, or --watch INTERVAL"))
+	}
+	if *interval > 0 && fs.NArg() > 0 {
+		return usageExitCode(usageError(fs, "--watch does not take file arguments"))
+	}
+	if err := src.parsed(fs); err != nil {
+		return usageExitCode(err)
+	}
+
+	if *interval == 0 {
+		return a.diffFiles(fs.Arg(0), fs.Arg(1))
+	}
+	current, _, err := a.loadMeta(ctx, src, a.stdout)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	return a.watch(ctx, src, *interval, current)
+}
+
+// exitUpdateAvailable is the exit status of diff --check-update when the ranges
+// changed, and of diff --verify-cache when the cache differs from them.
+const exitUpdateAvailable = 3
+
+// checkUpdate implements diff --check-update: a conditional request with the cached
+// ETag that reports whether the published ranges changed, leaving the cache alone.
+func (a *app) checkUpdate(ctx context.Context, src sourceOptions) int {
+	fetchCtx, cancel := context.WithTimeout(ctx, src.requestTimeout())
+	defer cancel()
+	updated, err := githubmeta.CheckForUpdate(fetchCtx, a.fetchOptions(src))
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	if !updated {
+		fmt.Fprintln(a.stdout, "up to date")
+		return 0
+	}
+	fmt.Fprintln(a.stdout, "update available")
+	return exitUpdateAvailable
+}
+
+// verifyCache implements diff --verify-cache: it downloads the ranges without the
+// cached ETag and lists how the cached copy differs from them.
+func (a *app) verifyCache(ctx context.Context, src sourceOptions) int {
+	fetchCtx, cancel := context.WithTimeout(ctx, src.requestTimeout())
+	defer cancel()
+	diff, err := githubmeta.VerifyCache(fetchCtx, a.fetchOptions(src))
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	if diff.Empty() {
+		fmt.Fprintln(a.stdout, "cache matches the live meta data")
+		return 0
+	}
+	fmt.Fprintf(a.stdout, "cache differs from the live meta data: %d added, %d removed\n", len(diff.Added), len(diff.Removed))
+	printDiffEntries(a.stdout, diff)
+	return exitUpdateAvailable
+}
+
+// diffFiles prints the entries added and removed between two snapshots.
+func (a *app) diffFiles(oldPath, newPath string) int {
+	old, err := githubmeta.LoadFromFile(oldPath)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	next, err := githubmeta.LoadFromFile(newPath)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	diff := githubmeta.DiffMeta(old, next)
+	fmt.Fprintf(a.stdout, "%s -> %s: %d added, %d removed\n", oldPath, newPath, len(diff.Added), len(diff.Removed))
+	printDiffEntries(a.stdout, diff)
+	return 0
+}
+
+// watch implements diff --watch: it re-fetches every interval (reusing the cached ETag)
+// and prints a timestamped diff whenever the published ranges change. It stays quiet
+// while nothing changes and returns once ctx is cancelled, e.g. by Ctrl-C.
+func (a *app) watch(ctx context.Context, src sourceOptions, interval time.Duration, current *githubmeta.MetaData) int {
+	fmt.Fprintf(a.stdout, "Watching for changes every %s (press Ctrl-C to stop)...\n", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-ticker.C:
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, src.requestTimeout())
+		next, err := a.fetch(fetchCtx, src)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0
+			}
+			fmt.Fprintf(a.stderr, "%s refresh failed: %v\n", time.Now().Format(time.RFC3339), err)
+			continue
+		}
+
+		if githubmeta.SameVersion(current, next) {
+			continue
+		}
+		diff := githubmeta.DiffMeta(current, next)
+		current = next
+		if diff.Empty() {
+			continue
+		}
+		printDiff(a.stdout, time.Now(), diff)
+	}
+}
+
+func printDiff(w io.Writer, at time.Time, diff githubmeta.MetaDiff) {
+	fmt.Fprintf(w, "%s GitHub meta data changed: %d added, %d removed\n", at.Format(time.RFC3339), len(diff.Added), len(diff.Removed))
+	printDiffEntries(w, diff)
+}
+
+func printDiffEntries(w io.Writer, diff githubmeta.MetaDiff) {
+	for _, entry := range diff.Added {
+		fmt.Fprintf(w, "  + %s %s\n", entry.Label, entry.Prefix)
+	}
+	for _, entry := range diff.Removed {
+		fmt.Fprintf(w, "  - %s %s\n", entry.Label, entry.Prefix)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for a writer goroutine and a polling reader.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunWatchPrintsDiffOnChange(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) <= 2 {
+			_, _ = w.Write([]byte(`{"web": ["140.82.112.0/20"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"web": ["140.82.112.0/20", "143.55.64.0/20"]}`))
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+
+	var stdout, stderr syncBuffer
+	a := &app{stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr, client: &http.Client{Transport: redirectTransport{target: target}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() {
+		done <- a.run(ctx, []string{"diff", "--cache-dir", t.TempDir(), "--watch", "10ms"})
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for !strings.Contains(stdout.String(), "  + web 143.55.64.0/20") {
+		select {
+		case <-deadline:
+			cancel()
+			t.Fatalf("timed out waiting for diff, output %q, errors %q", stdout.String(), stderr.String())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	if code := <-done; code != 0 {
+		t.Fatalf("watch exited %d", code)
+	}
+
+	out := stdout.String()
+	if strings.Count(out, "GitHub meta data changed: 1 added, 0 removed") != 1 {
+		t.Fatalf("expected exactly one change notice, got %q", out)
+	}
+}
+
+func TestRunDiffComparesSnapshots(t *testing.T) {
+	old := writeTestFile(t, "old.json", `{"web": ["140.82.112.0/20"], "api": ["192.30.252.0/24"]}`)
+	next := writeTestFile(t, "new.json", `{"web": ["140.82.112.0/20", "143.55.64.0/20"]}`)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"diff", old, next}); code != 0 {
+		t.Fatalf("diff exited %d: %s", code, stderr)
+	}
+	want := old + " -> " + next + ": 1 added, 1 removed\n  + web 143.55.64.0/20\n  - api 192.30.252.0/24\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("diff printed %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"unicode"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// runEmit implements the emit subcommand. For the labels given as arguments (every
+// label by default) it prints either the coalesced union of their prefixes, one per
+// line, or with --format json a label→prefixes map for infrastructure-as-code tools.
+// --format meta re-emits a meta.json-compatible document restricted to those labels,
+// and --format ipset writes an "ipset restore" file. Progress goes to stderr so
+// stdout is just the list.
+func (a *app) runEmit(ctx context.Context, args []string) int {
+	var src sourceOptions
+	fs := newFlagSet("emit", a.stderr)
+	src.addFlags(fs)
+	format := fs.String("format", "text", "output format: text (one prefix per line), json (label → prefixes map), meta (filtered meta.json) or ipset (ipset restore file)")
+	splitFamily := fs.Bool("split-family", false, `group the JSON map under "ipv4" and "ipv6" keys (requires --format json)`)
+	setName := fs.String("set-name", "github", `ipset name for the IPv4 prefixes; the IPv6 set gets a "6" suffix (requires --format ipset)`)
+	hashSize := fs.Int("hashsize", 0, "initial hash size of the ipsets (default: ipset's own default; requires --format ipset)")
+	if err := fs.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if *format != "text" && *format != "json" && *format != "meta" && *format != "ipset" {
+		return usageExitCode(usageError(fs, "invalid --format %q (expected text, json, meta or ipset)", *format))
+	}
+	if *splitFamily && *format != "json" {
+		return usageExitCode(usageError(fs, "--split-family requires --format json"))
+	}
+	ipsetFlag := false
+	fs.Visit(func(f *flag.Flag) { ipsetFlag = ipsetFlag || f.Name == "set-name" || f.Name == "hashsize" })
+	if ipsetFlag && *format != "ipset" {
+		return usageExitCode(usageError(fs, "--set-name and --hashsize require --format ipset"))
+	}
+	// ipset names are at most 31 characters, including the IPv6 set's suffix.
+	if *setName == "" || len(*setName) > 30 || strings.ContainsFunc(*setName, unicode.IsSpace) {
+		return usageExitCode(usageError(fs, "invalid --set-name %q (expected 1 to 30 characters without spaces)", *setName))
+	}
+	if *hashSize < 0 {
+		return usageExitCode(usageError(fs, "--hashsize must not be negative"))
+	}
+	if err := src.parsed(fs); err != nil {
+		return usageExitCode(err)
+	}
+
+	meta, _, err := a.loadMeta(ctx, src, a.stderr)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+
+	labels := fs.Args()
+	if len(labels) == 0 {
+		labels = meta.Labels()
+	}
+	byLabel := make(map[string][]netip.Prefix, len(labels))
+	for _, name := range labels {
+		// Labels match regardless of case but are reported as the document spells them.
+		label, ok := meta.ResolveLabel(name)
+		if !ok {
+			fmt.Fprintf(a.stderr, "error: unknown label %q\n", name)
+			return 1
+		}
+		byLabel[label] = meta.LabelPrefixes(label)
+	}
+
+	if *format == "meta" {
+		if err := writeJSON(a.stdout, meta.FilterLabels(labels...)); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if *format == "json" {
+		var v any = byLabel
+		if *splitFamily {
+			v = splitByFamily(byLabel)
+		}
+		if err := writeJSON(a.stdout, v); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	var all []netip.Prefix
+	for _, prefixes := range byLabel {
+		all = append(all, prefixes...)
+	}
+	if *format == "ipset" {
+		writeIPSet(a.stdout, *setName, *hashSize, githubmeta.Coalesce(all))
+		return 0
+	}
+	for _, p := range githubmeta.Coalesce(all) {
+		fmt.Fprintln(a.stdout, p)
+	}
+	return 0
+}
+
+// writeIPSet writes prefixes in "ipset save" format: a hash:net set named name for
+// the IPv4 prefixes and one named name+"6" for the IPv6 prefixes. Both sets are
+// always created so that firewall rules can refer to them. A positive hashSize is
+// passed on to both.
+func writeIPSet(w io.Writer, name string, hashSize int, prefixes []netip.Prefix) {
+	for _, set := range []struct {
+		name, family string
+		in           func(netip.Addr) bool
+	}{
+		{name, "inet", netip.Addr.Is4},
+		{name + "6", "inet6", netip.Addr.Is6},
+	} {
+		fmt.Fprintf(w, "create %s hash:net family %s", set.name, set.family)
+		if hashSize > 0 {
+			fmt.Fprintf(w, " hashsize %d", hashSize)
+		}
+		fmt.Fprintln(w)
+		for _, p := range prefixes {
+			if set.in(p.Addr()) {
+				fmt.Fprintf(w, "add %s %s\n", set.name, p)
+			}
+		}
+	}
+}
+
+// splitByFamily regroups a label→prefixes map under "ipv4" and "ipv6" keys, leaving
+// out labels with no prefixes in a family.
+func splitByFamily(byLabel map[string][]netip.Prefix) map[string]map[string][]netip.Prefix {
+	split := map[string]map[string][]netip.Prefix{"ipv4": {}, "ipv6": {}}
+	for label, prefixes := range byLabel {
+		for _, p := range prefixes {
+			family := "ipv6"
+			if p.Addr().Is4() {
+				family = "ipv4"
+			}
+			split[family][label] = append(split[family][label], p)
+		}
+	}
+	return split
+}
+
+// writeJSON writes v as indented JSON followed by a newline.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// jsonFieldNames returns the JSON keys of the exported fields of struct type t,
+// taken from their struct tags so the --fields vocabulary follows the result structs.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := jsonFieldName(t.Field(i)); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// jsonFieldName returns the JSON key of field, or false when it is not marshaled.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
+
+// parseFields splits a --fields list and rejects names that are not JSON keys of any of types.
+func parseFields(spec string, types ...reflect.Type) ([]string, error) {
+	var known []string
+	for _, t := range types {
+		for _, name := range jsonFieldNames(t) {
+			if !slices.Contains(known, name) {
+				known = append(known, name)
+			}
+		}
+	}
+	var fields []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown field %q (valid fields: %s)", name, strings.Join(known, ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// selectFields marshals v and keeps only the requested top-level keys.
+func selectFields(v any, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, name := range fields {
+		if value, ok := all[name]; ok {
+			selected[name] = value
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,40 @@
+package githubmeta
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FindSnapshot returns the path of the snapshot in dir named meta-YYYY-MM-DD.json
+// with the latest date that is not after on. Files not following the naming scheme
+// are ignored. It is an error if no snapshot predates on.
+func FindSnapshot(dir string, on time.Time) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("read archive: %w", err)
+	}
+	day := on.Format(time.DateOnly)
+
+	var best, bestDay string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "meta-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		date := strings.TrimSuffix(strings.TrimPrefix(name, "meta-"), ".json")
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			continue
+		}
+		// The fixed-width layout makes string order match date order.
+		if date <= day && date > bestDay {
+			best, bestDay = name, date
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no snapshot in %s on or before %s", dir, day)
+	}
+	return filepath.Join(dir, best), nil
+}
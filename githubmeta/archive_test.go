@@ -0,0 +1,43 @@
+package githubmeta
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"meta-2024-01-01.json", "meta-2024-03-15.json", "meta-2024-06-01.json", "meta-latest.json", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sampleMeta), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		on   string
+		want string
+	}{
+		{"2024-03-15", "meta-2024-03-15.json"},
+		{"2024-05-31", "meta-2024-03-15.json"},
+		{"2024-01-01", "meta-2024-01-01.json"},
+		{"2025-01-01", "meta-2024-06-01.json"},
+	}
+	for _, tt := range tests {
+		on, _ := time.Parse(time.DateOnly, tt.on)
+		got, err := FindSnapshot(dir, on)
+		if err != nil {
+			t.Fatalf("FindSnapshot(%s): %v", tt.on, err)
+		}
+		if got != filepath.Join(dir, tt.want) {
+			t.Errorf("FindSnapshot(%s) = %s, want %s", tt.on, got, tt.want)
+		}
+	}
+
+	on, _ := time.Parse(time.DateOnly, "2023-12-31")
+	if _, err := FindSnapshot(dir, on); err == nil || !strings.Contains(err.Error(), "no snapshot") {
+		t.Fatalf("expected an error for a date before every snapshot, got %v", err)
+	}
+}
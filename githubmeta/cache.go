@@ -0,0 +1,236 @@
+package githubmeta
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheInfo describes the files held in an on-disk cache directory.
+type CacheInfo struct {
+	Dir       string
+	HasMeta   bool
+	MetaSize  int64
+	HasETag   bool
+	ETagSize  int64
+	ETag      string
+	FetchedAt time.Time
+}
+
+// DefaultCacheDir returns the cache directory used by Fetch.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cidr-calculator-github"), nil
+}
+
+// resolveDefaultCacheDir returns DefaultCacheDir, falling back to $XDG_CACHE_HOME on
+// platforms whose UserCacheDir ignores it. It returns "" (caching disabled) with a
+// warning when neither is available, e.g. in minimal containers without $HOME.
+func resolveDefaultCacheDir(warnf func(format string, args ...any)) string {
+	dir, err := DefaultCacheDir()
+	if err == nil {
+		return dir
+	}
+	if xdg := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "cidr-calculator-github")
+	}
+	warnf("cannot determine a cache directory (%v); caching disabled", err)
+	return ""
+}
+
+// InspectCache reports which cache files exist in dir, their sizes, the stored ETag,
+// and when the cached payload was written. Missing files are not an error.
+func InspectCache(dir string) (CacheInfo, error) {
+	info := CacheInfo{Dir: dir}
+	store := newCacheStore(dir)
+	if store == nil {
+		return info, errors.New("cache directory is empty")
+	}
+
+	metaStat, err := os.Stat(store.metaPath())
+	switch {
+	case err == nil:
+		info.HasMeta = true
+		info.MetaSize = metaStat.Size()
+		info.FetchedAt = metaStat.ModTime()
+	case !errors.Is(err, fs.ErrNotExist):
+		return info, err
+	}
+
+	etagStat, err := os.Stat(store.etagPath())
+	switch {
+	case err == nil:
+		info.HasETag = true
+		info.ETagSize = etagStat.Size()
+		info.ETag = store.readETag()
+	case !errors.Is(err, fs.ErrNotExist):
+		return info, err
+	}
+
+	return info, nil
+}
+
+// ClearCache removes the cached payload, ETag and checksum from dir. It is safe to call
+// when the files (or the directory) do not exist.
+func ClearCache(dir string) error {
+	store := newCacheStore(dir)
+	if store == nil {
+		return errors.New("cache directory is empty")
+	}
+	for _, path := range []string{store.metaPath(), store.etagPath(), store.sumPath(), store.resolvedPath()} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+type cacheStore struct {
+	dir string
+	// readOnly skips every write so a pre-seeded cache can be used on read-only media.
+	readOnly bool
+	// warnf reports non-fatal cache problems.
+	warnf func(format string, args ...any)
+	// pin, when set, is the hex SHA-256 the cached payload must have.
+	pin string
+	// families are the address families load keeps.
+	families Families
+}
+
+func newCacheStore(dir string) *cacheStore {
+	if dir == "" {
+		return nil
+	}
+	return &cacheStore{dir: dir, warnf: func(string, ...any) {}}
+}
+
+func (c *cacheStore) metaPath() string {
+	return filepath.Join(c.dir, "meta.json")
+}
+
+func (c *cacheStore) etagPath() string {
+	return filepath.Join(c.dir, "meta.etag")
+}
+
+// sumPath holds the hex SHA-256 of meta.json, written alongside it so tampering
+// or corruption is detected on load.
+func (c *cacheStore) sumPath() string {
+	return filepath.Join(c.dir, "meta.sha256")
+}
+
+func (c *cacheStore) readETag() string {
+	if c == nil {
+		return ""
+	}
+	data, err := os.ReadFile(c.etagPath())
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(data))
+}
+
+// clearETag forgets the stored ETag so the next request is unconditional.
+func (c *cacheStore) clearETag() {
+	if c == nil || c.readOnly {
+		return
+	}
+	_ = os.Remove(c.etagPath())
+}
+
+// age returns how long ago the cached payload was downloaded or last revalidated,
+// and false when there is none.
+func (c *cacheStore) age() (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	fi, err := os.Stat(c.metaPath())
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(fi.ModTime()), true
+}
+
+// touch records that the cached payload was just revalidated.
+func (c *cacheStore) touch() {
+	if c == nil || c.readOnly {
+		return
+	}
+	now := time.Now()
+	_ = os.Chtimes(c.metaPath(), now, now)
+}
+
+func (c *cacheStore) load() (*MetaData, error) {
+	if c == nil {
+		return nil, errors.New("cache disabled")
+	}
+	raw, err := os.ReadFile(c.metaPath())
+	if err != nil {
+		return nil, err
+	}
+	sum := payloadSHA256(raw)
+	// Caches written before checksums were recorded have no meta.sha256 and are trusted.
+	if want, err := os.ReadFile(c.sumPath()); err == nil && string(bytes.TrimSpace(want)) != sum {
+		return nil, fmt.Errorf("%w: %s has SHA-256 %s but %s records %s",
+			ErrChecksumMismatch, c.metaPath(), sum, c.sumPath(), bytes.TrimSpace(want))
+	}
+	if c.pin != "" && sum != c.pin {
+		return nil, fmt.Errorf("%w: cached %s has SHA-256 %s, expected %s", ErrChecksumMismatch, c.metaPath(), sum, c.pin)
+	}
+	meta, err := parse(bytes.NewReader(raw), c.families)
+	if err != nil {
+		return nil, err
+	}
+	meta.etag = c.readETag()
+	meta.source = SourceCache
+	return meta, nil
+}
+
+func (c *cacheStore) save(raw []byte, etag string) error {
+	if c == nil || c.readOnly {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.metaPath(), raw, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.sumPath(), []byte(payloadSHA256(raw)+"\n"), 0o644); err != nil {
+		return err
+	}
+	if etag != "" {
+		if err := os.WriteFile(c.etagPath(), []byte(etag), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fallback serves the cache in place of a request that failed with reqErr. A cache
+// failing checksum verification is reported alongside reqErr rather than skipped,
+// so tampering is not mistaken for a plain outage.
+func (c *cacheStore) fallback(reqErr error) (*MetaData, error) {
+	meta, err := c.load()
+	if err == nil {
+		return meta, nil
+	}
+	if errors.Is(err, ErrChecksumMismatch) {
+		return nil, errors.Join(reqErr, err)
+	}
+	return nil, reqErr
+}
+
+// payloadSHA256 returns the lowercase hex SHA-256 of raw.
+func payloadSHA256(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,326 @@
+package githubmeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInspectCache_ReportsSavedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := newCacheStore(dir).save([]byte(sampleMeta), `"v1"`); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	info, err := InspectCache(dir)
+	if err != nil {
+		t.Fatalf("InspectCache returned error: %v", err)
+	}
+	if !info.HasMeta || info.MetaSize != int64(len(sampleMeta)) {
+		t.Fatalf("expected meta.json of %d bytes, got %+v", len(sampleMeta), info)
+	}
+	if !info.HasETag || info.ETag != `"v1"` {
+		t.Fatalf("expected etag \"v1\", got %+v", info)
+	}
+	if info.FetchedAt.IsZero() {
+		t.Fatalf("expected fetch timestamp to be set")
+	}
+}
+
+func TestClearCache_RemovesFilesAndIsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+	if err := newCacheStore(dir).save([]byte(sampleMeta), `"v1"`); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	if err := ClearCache(dir); err != nil {
+		t.Fatalf("ClearCache returned error: %v", err)
+	}
+	if err := ClearCache(dir); err != nil {
+		t.Fatalf("second ClearCache returned error: %v", err)
+	}
+
+	info, err := InspectCache(dir)
+	if err != nil {
+		t.Fatalf("InspectCache returned error: %v", err)
+	}
+	if info.HasMeta || info.HasETag {
+		t.Fatalf("expected cache files to be gone, got %+v", info)
+	}
+}
+
+func TestFetchWithOptions_ReadOnlyCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	emptyDir := t.TempDir()
+	if _, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), CacheDir: emptyDir, ReadOnlyCache: true}); err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if files, _ := os.ReadDir(emptyDir); len(files) != 0 {
+		t.Fatalf("expected no files written to a read-only cache, found %d", len(files))
+	}
+
+	seededDir := t.TempDir()
+	if err := newCacheStore(seededDir).save([]byte(sampleMeta), `"v1"`); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	meta, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), CacheDir: seededDir, ReadOnlyCache: true})
+	if err != nil {
+		t.Fatalf("expected pre-seeded cache to be served on 304, got %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 cached entries, got %d", len(meta.Entries()))
+	}
+}
+
+func TestFetchWithOptions_WarnsWhenDefaultCacheDirUnavailable(t *testing.T) {
+	t.Setenv("HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("LocalAppData", "")
+	if _, err := DefaultCacheDir(); err == nil {
+		t.Skip("os.UserCacheDir cannot be made to fail on this platform")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	var warnings []string
+	meta, err := FetchWithOptions(context.Background(), FetchOptions{
+		Client:             srv.Client(),
+		UseDefaultCacheDir: true,
+		Warnf: func(format string, args ...any) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		},
+	})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(meta.Entries()))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "caching disabled") {
+		t.Fatalf("expected a caching-disabled warning, got %q", warnings)
+	}
+}
+
+func TestFetchWithOptions_TracesCacheDecisions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	dir := t.TempDir()
+	fetchTrace := func() string {
+		var trace []string
+		_, err := FetchWithOptions(context.Background(), FetchOptions{
+			Client:   srv.Client(),
+			CacheDir: dir,
+			Tracef: func(format string, args ...any) {
+				trace = append(trace, fmt.Sprintf(format, args...))
+			},
+		})
+		if err != nil {
+			t.Fatalf("fetch failed: %v", err)
+		}
+		return strings.Join(trace, "\n")
+	}
+
+	if trace := fetchTrace(); !strings.Contains(trace, "no cached ETag") || !strings.Contains(trace, "200 → served from the network and saved to the cache") {
+		t.Fatalf("unexpected trace for the first fetch:\n%s", trace)
+	}
+	if trace := fetchTrace(); !strings.Contains(trace, `sending If-None-Match "v1"`) || !strings.Contains(trace, "304 → served from cache") {
+		t.Fatalf("unexpected trace for the revalidation:\n%s", trace)
+	}
+}
+
+func TestCacheStore_VerifiesChecksum(t *testing.T) {
+	dir := t.TempDir()
+	store := newCacheStore(dir)
+	if err := store.save([]byte(sampleMeta), `"v1"`); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if _, err := store.load(); err != nil {
+		t.Fatalf("expected a matching checksum to load, got %v", err)
+	}
+
+	tampered := strings.Replace(sampleMeta, "140.82.112.0/20", "203.0.113.0/24", 1)
+	if err := os.WriteFile(store.metaPath(), []byte(tampered), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.load(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch for a tampered cache, got %v", err)
+	}
+
+	// An offline fetch must report the tampering rather than a bare network error.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+	if _, err := FetchWithCacheDir(context.Background(), srv.Client(), dir); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected the tampered cache to be refused, got %v", err)
+	}
+}
+
+func TestFetchWithOptions_ExpectSHA256(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	good := payloadSHA256([]byte(sampleMeta))
+	if _, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), ExpectSHA256: strings.ToUpper(good)}); err != nil {
+		t.Fatalf("expected the pinned payload to load, got %v", err)
+	}
+
+	dir := t.TempDir()
+	_, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), CacheDir: dir, ExpectSHA256: strings.Repeat("0", 64)})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, statErr := os.Stat(newCacheStore(dir).metaPath()); !os.IsNotExist(statErr) {
+		t.Fatalf("a payload failing the pin must not be cached, stat err = %v", statErr)
+	}
+}
+
+func TestFetchWithOptions_FamiliesDropsOtherFamilyOnParse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"hooks": ["192.30.252.0/22", "2001:db8:1::/48"], "v6only": ["2001:db8:2::/48"]}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	// The first fetch parses the response and the second the cached copy.
+	for _, want := range []Source{SourceNetwork, SourceCache} {
+		meta, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), CacheDir: dir, Endpoint: srv.URL, Families: IPv4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Source() != want {
+			t.Fatalf("source %s, want %s", meta.Source(), want)
+		}
+		entries := meta.Entries()
+		if len(entries) != 1 || !entries[0].Prefix.Addr().Is4() {
+			t.Fatalf("expected only the IPv4 entry, got %v", entries)
+		}
+		if doc, _ := json.Marshal(meta); strings.Contains(string(doc), "v6only") {
+			t.Fatalf("a label of the dropped family should not be kept as a non-CIDR field: %s", doc)
+		}
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+	if err != nil || !strings.Contains(string(raw), "2001:db8:1::/48") {
+		t.Fatalf("the cache should hold the whole document, got %q (%v)", raw, err)
+	}
+}
+
+func TestFetchWithOptions_PreferCacheSkipsRequestWhileFresh(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	opts := FetchOptions{Client: srv.Client(), CacheDir: dir, Endpoint: srv.URL, PreferCache: true, CacheTTL: time.Hour}
+	fetchSource := func() Source {
+		t.Helper()
+		meta, err := FetchWithOptions(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meta.Source()
+	}
+
+	// Without a cache the first fetch goes to the network.
+	if got := fetchSource(); got != SourceNetwork || requests != 1 {
+		t.Fatalf("first fetch: source %s after %d requests, want network after 1", got, requests)
+	}
+	if got := fetchSource(); got != SourceCache || requests != 1 {
+		t.Fatalf("fresh cache: source %s after %d requests, want cache without a request", got, requests)
+	}
+
+	// Past the TTL the cache is revalidated, and the 304 makes it fresh again.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "meta.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if got := fetchSource(); got != SourceCache || requests != 2 {
+		t.Fatalf("stale cache: source %s after %d requests, want cache after a revalidation", got, requests)
+	}
+	if got := fetchSource(); got != SourceCache || requests != 2 {
+		t.Fatalf("revalidated cache: source %s after %d requests, want cache without a request", got, requests)
+	}
+
+	// Without PreferCache every fetch revalidates.
+	opts.PreferCache = false
+	fetchSource()
+	if requests != 3 {
+		t.Fatalf("expected a conditional request without PreferCache, got %d requests", requests)
+	}
+}
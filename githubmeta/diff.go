@@ -0,0 +1,56 @@
+package githubmeta
+
+// MetaDiff lists the entries that differ between two meta data snapshots.
+type MetaDiff struct {
+	Added   []Entry
+	Removed []Entry
+}
+
+// Empty reports whether the two snapshots contained the same entries.
+func (d MetaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// DiffMeta compares two snapshots entry by entry. A nil snapshot is treated as empty.
+// Added and Removed keep the label/prefix ordering of Entries.
+func DiffMeta(old, new *MetaData) MetaDiff {
+	oldEntries, newEntries := old.Entries(), new.Entries()
+	oldSet := make(map[Entry]struct{}, len(oldEntries))
+	for _, entry := range oldEntries {
+		oldSet[entry] = struct{}{}
+	}
+	newSet := make(map[Entry]struct{}, len(newEntries))
+	for _, entry := range newEntries {
+		newSet[entry] = struct{}{}
+	}
+
+	var diff MetaDiff
+	for _, entry := range newEntries {
+		if _, ok := oldSet[entry]; !ok {
+			diff.Added = append(diff.Added, entry)
+		}
+	}
+	for _, entry := range oldEntries {
+		if _, ok := newSet[entry]; !ok {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+	return diff
+}
+
+// Equal reports whether a and b hold the same entries. Fields without CIDRs and
+// ETags are not compared.
+func Equal(a, b *MetaData) bool {
+	return DiffMeta(a, b).Empty()
+}
+
+// SameVersion reports whether a and b are the same version of the meta data, so a
+// caller can skip re-parsing or diffing. When both carry an ETag the ETags are
+// compared verbatim, which is cheap and matches the server's notion of a version;
+// otherwise it falls back to Equal.
+func SameVersion(a, b *MetaData) bool {
+	if a.ETag() != "" && b.ETag() != "" {
+		return a.ETag() == b.ETag()
+	}
+	return Equal(a, b)
+}
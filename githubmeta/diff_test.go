@@ -0,0 +1,58 @@
+package githubmeta
+
+import "testing"
+
+func TestDiffMeta(t *testing.T) {
+	old := loadFixture(t, `{"web": ["140.82.112.0/20"], "api": ["192.30.252.0/24"]}`)
+	updated := loadFixture(t, `{"web": ["140.82.112.0/20", "143.55.64.0/20"], "hooks": ["192.30.252.0/22"]}`)
+
+	diff := DiffMeta(old, updated)
+	if len(diff.Added) != 2 || diff.Added[0].Label != "hooks" || diff.Added[1].Prefix.String() != "143.55.64.0/20" {
+		t.Fatalf("unexpected added entries: %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Label != "api" {
+		t.Fatalf("unexpected removed entries: %v", diff.Removed)
+	}
+	if !DiffMeta(old, old).Empty() {
+		t.Fatalf("expected identical snapshots to produce an empty diff")
+	}
+}
+
+func TestSameVersionComparesETags(t *testing.T) {
+	loadCached := func(dir, etag string) *MetaData {
+		t.Helper()
+		store := newCacheStore(dir)
+		if err := store.save([]byte(sampleMeta), etag); err != nil {
+			t.Fatal(err)
+		}
+		meta, err := store.load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meta
+	}
+	dir := t.TempDir()
+	first := loadCached(dir, `"v1"`)
+	reparsed, err := newCacheStore(dir).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ETag() != `"v1"` || !SameVersion(first, reparsed) {
+		t.Fatalf("expected re-parsed snapshots with ETag %q to be the same version", first.ETag())
+	}
+
+	// Identical content under a different ETag is a different version.
+	other := loadCached(t.TempDir(), `"v2"`)
+	if SameVersion(first, other) {
+		t.Fatal("expected differing ETags to report different versions")
+	}
+}
+
+func TestSameVersionFallsBackToEqual(t *testing.T) {
+	a := loadFixture(t, `{"web": ["140.82.112.0/20"]}`)
+	b := loadFixture(t, `{"web": ["140.82.112.0/20"]}`)
+	c := loadFixture(t, `{"web": ["140.82.112.0/20", "143.55.64.0/20"]}`)
+	if !SameVersion(a, b) || SameVersion(a, c) {
+		t.Fatal("expected snapshots without ETags to be compared by their entries")
+	}
+}
@@ -0,0 +1,28 @@
+package githubmeta
+
+import (
+	"bytes"
+	_ "embed"
+)
+
+// embeddedMetaJSON is a snapshot of the meta endpoint bundled at build time. It only
+// changes when the file is refreshed in the source tree, so it may be stale.
+//
+//go:embed embedded_meta.json
+var embeddedMetaJSON []byte
+
+// EmbeddedMeta parses the meta data snapshot bundled with the package. It is the last
+// resort of FetchOptions.EmbeddedFallback and may lag behind the published ranges.
+func EmbeddedMeta() (*MetaData, error) {
+	return embeddedMeta(AllFamilies)
+}
+
+// embeddedMeta is EmbeddedMeta keeping only the prefixes of families.
+func embeddedMeta(families Families) (*MetaData, error) {
+	meta, err := parse(bytes.NewReader(embeddedMetaJSON), families)
+	if err != nil {
+		return nil, err
+	}
+	meta.source = SourceEmbedded
+	return meta, nil
+}
@@ -0,0 +1,96 @@
+package githubmeta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedMetaParses(t *testing.T) {
+	entries, err := parseMetaJSON(bytes.NewReader(embeddedMetaJSON))
+	if err != nil {
+		t.Fatalf("embedded snapshot does not parse: %v", err)
+	}
+	meta, err := EmbeddedMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Entries()) != len(entries) {
+		t.Fatalf("EmbeddedMeta has %d entries, parseMetaJSON %d", len(meta.Entries()), len(entries))
+	}
+	if labels := meta.Lookup(netip.MustParseAddr("140.82.112.3")); len(labels) == 0 {
+		t.Fatal("expected a well-known GitHub address in the embedded snapshot")
+	}
+}
+
+func TestFetch_DoneContextSkipsEmbeddedFallback(t *testing.T) {
+	// Point the default cache directory at an empty one.
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if meta, err := Fetch(cancelled, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v (meta %v)", err, meta)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	var warnings []string
+	opts := FetchOptions{
+		CacheDir:         t.TempDir(),
+		EmbeddedFallback: true,
+		Warnf: func(format string, args ...any) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		},
+	}
+	if meta, err := FetchWithOptions(expired, opts); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v (meta %v)", err, meta)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no fallback warning, got %q", warnings)
+	}
+}
+
+func TestFetchWithOptions_EmbeddedFallbackWhenOffline(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	var warnings []string
+	opts := FetchOptions{
+		Client:           srv.Client(),
+		CacheDir:         t.TempDir(),
+		EmbeddedFallback: true,
+		Warnf: func(format string, args ...any) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		},
+	}
+	meta, err := FetchWithOptions(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("expected the embedded fallback, got %v", err)
+	}
+	embedded, _ := EmbeddedMeta()
+	if len(meta.Entries()) != len(embedded.Entries()) {
+		t.Fatalf("expected the embedded snapshot, got %d entries", len(meta.Entries()))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "bundled meta data snapshot, which may be stale") {
+		t.Fatalf("expected a staleness warning, got %q", warnings)
+	}
+
+	opts.EmbeddedFallback = false
+	if _, err := FetchWithOptions(context.Background(), opts); err == nil {
+		t.Fatal("expected an error without the embedded fallback")
+	}
+}
@@ -0,0 +1,104 @@
+package githubmeta
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encodedFormat identifies the Encode format; Decode rejects anything else.
+const encodedFormat = "githubmeta-index/1"
+
+// ErrBadIndex is returned by Decode for input that is not an Encode blob of a
+// supported format or whose index is inconsistent.
+var ErrBadIndex = errors.New("invalid meta index")
+
+// encodedMeta is the gob form of a MetaData. The entries of each prefix index are
+// stored in index order, so Decode skips the sort and only links them.
+type encodedMeta struct {
+	Format        string
+	Entries       []Entry
+	Index4        []Entry
+	Index6        []Entry
+	Extra         map[string]json.RawMessage
+	ETag          string
+	Source        Source
+	SchemaVersion string
+	Duplicates    []Entry
+}
+
+// Encode writes m, with its prefix indexes, in a compact binary form that Decode
+// loads without parsing JSON or sorting the indexes. The format is private to this
+// package and may change between versions, so blobs should be rebuilt from the meta
+// document after an upgrade rather than archived.
+func (m *MetaData) Encode(w io.Writer) error {
+	if m == nil {
+		return errors.New("nil MetaData")
+	}
+	return gob.NewEncoder(w).Encode(encodedMeta{
+		Format:        encodedFormat,
+		Entries:       m.entries,
+		Index4:        m.index4.entries,
+		Index6:        m.index6.entries,
+		Extra:         m.extra,
+		ETag:          m.etag,
+		Source:        m.source,
+		SchemaVersion: m.schemaVersion,
+		Duplicates:    m.duplicates,
+	})
+}
+
+// Decode reads a MetaData written by Encode. Lookups on the result behave exactly
+// like lookups on the encoded MetaData.
+func Decode(r io.Reader) (*MetaData, error) {
+	var enc encodedMeta
+	if err := gob.NewDecoder(r).Decode(&enc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadIndex, err)
+	}
+	if enc.Format != encodedFormat {
+		return nil, fmt.Errorf("%w: unsupported format %q (expected %q)", ErrBadIndex, enc.Format, encodedFormat)
+	}
+
+	m := &MetaData{
+		entries:       enc.Entries,
+		extra:         enc.Extra,
+		etag:          enc.ETag,
+		source:        enc.Source,
+		schemaVersion: enc.SchemaVersion,
+		duplicates:    enc.Duplicates,
+	}
+	for _, entry := range m.entries {
+		if entry.Prefix.Addr().Is4() {
+			m.entries4 = append(m.entries4, entry)
+		} else {
+			m.entries6 = append(m.entries6, entry)
+		}
+	}
+	var err error
+	if m.index4, err = decodeIndex(enc.Index4, len(m.entries4), true); err != nil {
+		return nil, err
+	}
+	if m.index6, err = decodeIndex(enc.Index6, len(m.entries6), false); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeIndex links the n stored entries of one family into a prefixIndex after
+// checking that they are in index order, which containing relies on.
+func decodeIndex(sorted []Entry, n int, is4 bool) (prefixIndex, error) {
+	if len(sorted) != n {
+		return prefixIndex{}, fmt.Errorf("%w: index does not match the entries", ErrBadIndex)
+	}
+	for i, entry := range sorted {
+		if !entry.Prefix.IsValid() || entry.Prefix.Addr().Is4() != is4 {
+			return prefixIndex{}, fmt.Errorf("%w: entry %d has an invalid prefix", ErrBadIndex, i)
+		}
+		if i > 0 && comparePrefixes(sorted[i-1].Prefix.Masked(), entry.Prefix.Masked()) > 0 {
+			return prefixIndex{}, fmt.Errorf("%w: index entries are not sorted", ErrBadIndex)
+		}
+	}
+	return linkPrefixIndex(sorted), nil
+}
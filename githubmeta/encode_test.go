@@ -0,0 +1,65 @@
+package githubmeta
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for name, meta := range map[string]*MetaData{
+		"fixture": loadFixture(t, nestedMeta),
+		"random":  newMetaData(randomEntries(rnd, 500)),
+	} {
+		meta.etag = `"abc"`
+		var buf bytes.Buffer
+		if err := meta.Encode(&buf); err != nil {
+			t.Fatalf("%s: Encode: %v", name, err)
+		}
+		decoded, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", name, err)
+		}
+
+		addrs := []netip.Addr{netip.MustParseAddr("2001:db8:1:2::1"), netip.MustParseAddr("2001:db9::1")}
+		for i := 0; i < 2000; i++ {
+			addrs = append(addrs, randomAddr4(rnd))
+		}
+		for _, addr := range addrs {
+			if got, want := strings.Join(decoded.Lookup(addr), ","), strings.Join(meta.Lookup(addr), ","); got != want {
+				t.Fatalf("%s: decoded Lookup(%s) = %q, want %q", name, addr, got, want)
+			}
+		}
+		if decoded.ETag() != meta.ETag() {
+			t.Fatalf("%s: ETag = %q, want %q", name, decoded.ETag(), meta.ETag())
+		}
+		got, _ := json.Marshal(decoded)
+		want, _ := json.Marshal(meta)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: decoded document differs:\n%s\n%s", name, got, want)
+		}
+	}
+}
+
+func TestDecodeRejectsInvalidInput(t *testing.T) {
+	if _, err := Decode(strings.NewReader(sampleMeta)); !errors.Is(err, ErrBadIndex) {
+		t.Fatalf("expected ErrBadIndex for JSON input, got %v", err)
+	}
+
+	// An index whose entries are out of order would make Lookup miss matches.
+	meta := loadFixture(t, nestedMeta)
+	entries := meta.index4.entries
+	entries[0], entries[1] = entries[1], entries[0]
+	var buf bytes.Buffer
+	if err := meta.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(&buf); !errors.Is(err, ErrBadIndex) {
+		t.Fatalf("expected ErrBadIndex for an unsorted index, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package githubmeta
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"strings"
+)
+
+// CIDRResult summarises the ownership of every address in a prefix.
+type CIDRResult struct {
+	// Prefix is the evaluated network; host bits in the input are masked off.
+	Prefix netip.Prefix
+	// Total is the number of addresses in Prefix.
+	Total *big.Int
+	// Owned is the number of addresses matching at least one label.
+	Owned int
+	// LabelSets counts owned addresses by the comma-joined set of labels they matched,
+	// e.g. "api, hooks".
+	LabelSets map[string]int
+}
+
+// ErrRangeTooLarge is matched, with errors.Is, by the error EvaluatePrefix returns for
+// a prefix over its limit. errors.As with a *RangeTooLargeError gives the sizes.
+var ErrRangeTooLarge = errors.New("CIDR too large to evaluate")
+
+// RangeTooLargeError reports a prefix that EvaluatePrefix refused because it holds
+// more addresses than the limit, leaving the caller to decide what to do with it,
+// e.g. evaluate it in pieces or queue it for later.
+type RangeTooLargeError struct {
+	// Prefix is the refused network, with host bits masked off.
+	Prefix netip.Prefix
+	// Count is the number of addresses in Prefix.
+	Count *big.Int
+	// Limit is the limit Count exceeds.
+	Limit int
+}
+
+func (e *RangeTooLargeError) Error() string {
+	return fmt.Sprintf("CIDR too large to evaluate (%s addresses exceeds the limit of %d); a /%d would fit under the %d limit",
+		e.Count, e.Limit, FittingPrefixBits(e.Prefix, e.Limit), e.Limit)
+}
+
+// Is makes every RangeTooLargeError match ErrRangeTooLarge.
+func (e *RangeTooLargeError) Is(target error) bool {
+	return target == ErrRangeTooLarge
+}
+
+// EvaluatePrefix looks up every address in p and tallies the results. It refuses
+// prefixes containing more than limit addresses with a *RangeTooLargeError; in that
+// case the returned result still carries Prefix and Total so callers can report the
+// size.
+func (m *MetaData) EvaluatePrefix(p netip.Prefix, limit int) (CIDRResult, error) {
+	return m.EvaluatePrefixFunc(p, limit, nil)
+}
+
+// EvaluatePrefixFunc is EvaluatePrefix with a callback invoked, in address order,
+// with each address and the labels it matched (empty when not owned).
+func (m *MetaData) EvaluatePrefixFunc(p netip.Prefix, limit int, visit func(addr netip.Addr, labels []string)) (CIDRResult, error) {
+	if !p.IsValid() {
+		return CIDRResult{}, errors.New("invalid prefix")
+	}
+	if limit < 1 {
+		return CIDRResult{}, errors.New("limit must be at least 1")
+	}
+
+	p = p.Masked()
+	res := CIDRResult{
+		Prefix:    p,
+		Total:     PrefixSize(p),
+		LabelSets: map[string]int{},
+	}
+	if res.Total.Cmp(big.NewInt(int64(limit))) > 0 {
+		return res, &RangeTooLargeError{Prefix: p, Count: new(big.Int).Set(res.Total), Limit: limit}
+	}
+
+	for addr := range PrefixAddrs(p) {
+		labels := m.Lookup(addr)
+		if len(labels) > 0 {
+			res.Owned++
+			res.LabelSets[strings.Join(labels, ", ")]++
+		}
+		if visit != nil {
+			visit(addr, labels)
+		}
+	}
+	return res, nil
+}
+
+// PrefixAddrs yields every address in p in ascending order, from the network address
+// through LastAddr(p). Host bits in p are ignored. It stops after the last address
+// instead of wrapping, so prefixes ending at 255.255.255.255 or ffff::ffff are safe.
+func PrefixAddrs(p netip.Prefix) iter.Seq[netip.Addr] {
+	if !p.IsValid() {
+		return func(func(netip.Addr) bool) {}
+	}
+	return AddrRange(FirstAddr(p), LastAddr(p))
+}
+
+// AddrRange yields every address from first through last in ascending order. It
+// yields nothing when either is invalid, they belong to different families, or
+// last is below first.
+func AddrRange(first, last netip.Addr) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		if !first.IsValid() || !last.IsValid() || first.Is4() != last.Is4() || last.Less(first) {
+			return
+		}
+		for addr := first; ; addr = addr.Next() {
+			if !yield(addr) || addr == last {
+				return
+			}
+		}
+	}
+}
+
+// FittingPrefixBits returns the shortest prefix length, in the address family of p,
+// whose blocks contain at most limit addresses; e.g. 20 for an IPv4 prefix and a limit
+// of 4096. It returns -1 when limit is below 1.
+func FittingPrefixBits(p netip.Prefix, limit int) int {
+	if limit < 1 {
+		return -1
+	}
+	bitLen := p.Addr().BitLen()
+	hostBits := bits.Len(uint(limit)) - 1
+	if hostBits >= bitLen {
+		return 0
+	}
+	return bitLen - hostBits
+}
+
+// FirstAddr returns the network address of p, i.e. its lowest address.
+func FirstAddr(p netip.Prefix) netip.Addr {
+	if !p.IsValid() {
+		return netip.Addr{}
+	}
+	return p.Masked().Addr()
+}
+
+// LastAddr returns the highest address contained in p.
+func LastAddr(p netip.Prefix) netip.Addr {
+	if !p.IsValid() {
+		return netip.Addr{}
+	}
+	p = p.Masked()
+	hostBits := p.Addr().BitLen() - p.Bits()
+
+	if p.Addr().Is4() {
+		a := p.Addr().As4()
+		v := binary.BigEndian.Uint32(a[:]) | uint32(uint64(1)<<hostBits-1)
+		binary.BigEndian.PutUint32(a[:], v)
+		return netip.AddrFrom4(a)
+	}
+
+	a := p.Addr().As16()
+	hi, lo := binary.BigEndian.Uint64(a[:8]), binary.BigEndian.Uint64(a[8:])
+	if hostBits >= 64 {
+		lo = math.MaxUint64
+		hi |= uint64(1)<<(hostBits-64) - 1
+	} else {
+		lo |= uint64(1)<<hostBits - 1
+	}
+	binary.BigEndian.PutUint64(a[:8], hi)
+	binary.BigEndian.PutUint64(a[8:], lo)
+	return netip.AddrFrom16(a)
+}
+
+// AddrToInt returns the big-endian integer form of addr: a 32-bit value for IPv4 and
+// a 128-bit value for IPv6 (including IPv4-mapped IPv6). It returns nil for the zero Addr.
+func AddrToInt(addr netip.Addr) *big.Int {
+	if !addr.IsValid() {
+		return nil
+	}
+	if addr.Is4() {
+		a := addr.As4()
+		return new(big.Int).SetBytes(a[:])
+	}
+	a := addr.As16()
+	return new(big.Int).SetBytes(a[:])
+}
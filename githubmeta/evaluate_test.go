@@ -0,0 +1,193 @@
+package githubmeta
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestLastAddr(t *testing.T) {
+	cases := map[string]string{
+		"192.30.252.0/22":   "192.30.255.255",
+		"140.82.112.0/20":   "140.82.127.255",
+		"10.0.0.7/32":       "10.0.0.7",
+		"0.0.0.0/0":         "255.255.255.255",
+		"2001:db8:1::/48":   "2001:db8:1:ffff:ffff:ffff:ffff:ffff",
+		"2001:db8::/127":    "2001:db8::1",
+		"2001:db8::abcd/64": "2001:db8::ffff:ffff:ffff:ffff",
+		"2001:db8::/96":     "2001:db8::ffff:ffff",
+		"::/0":              "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+	}
+	for in, want := range cases {
+		if got := LastAddr(netip.MustParsePrefix(in)); got.String() != want {
+			t.Errorf("LastAddr(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestFirstAddr(t *testing.T) {
+	cases := map[string]string{
+		"192.30.252.42/22":  "192.30.252.0",
+		"10.0.0.7/32":       "10.0.0.7",
+		"0.0.0.0/0":         "0.0.0.0",
+		"2001:db8:1::9/48":  "2001:db8:1::",
+		"2001:db8::abcd/64": "2001:db8::",
+		"::/0":              "::",
+	}
+	for in, want := range cases {
+		if got := FirstAddr(netip.MustParsePrefix(in)); got.String() != want {
+			t.Errorf("FirstAddr(%s) = %s, want %s", in, got, want)
+		}
+	}
+	if FirstAddr(netip.Prefix{}).IsValid() || LastAddr(netip.Prefix{}).IsValid() {
+		t.Errorf("expected invalid addresses for an invalid prefix")
+	}
+}
+
+func TestEvaluatePrefix(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	res, err := meta.EvaluatePrefix(netip.MustParsePrefix("192.30.251.254/23"), 4096)
+	if err != nil {
+		t.Fatalf("EvaluatePrefix returned error: %v", err)
+	}
+	if res.Prefix.String() != "192.30.250.0/23" || res.Total.Int64() != 512 || res.Owned != 0 {
+		t.Fatalf("unexpected result for unowned range: %+v", res)
+	}
+
+	res, err = meta.EvaluatePrefix(netip.MustParsePrefix("192.30.253.254/31"), 4096)
+	if err != nil {
+		t.Fatalf("EvaluatePrefix returned error: %v", err)
+	}
+	if res.Total.Int64() != 2 || res.Owned != 2 || res.LabelSets["hooks"] != 2 {
+		t.Fatalf("unexpected result for hooks range: %+v", res)
+	}
+
+	res, err = meta.EvaluatePrefix(netip.MustParsePrefix("192.30.252.0/23"), 4096)
+	if err != nil {
+		t.Fatalf("EvaluatePrefix returned error: %v", err)
+	}
+	if res.Owned != 512 || res.LabelSets["api, hooks"] != 256 || res.LabelSets["hooks"] != 256 || len(res.LabelSets) != 2 {
+		t.Fatalf("unexpected label distribution: %+v", res)
+	}
+}
+
+func TestEvaluatePrefixRespectsLimit(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	res, err := meta.EvaluatePrefix(netip.MustParsePrefix("140.82.0.0/16"), 4096)
+	if err == nil {
+		t.Fatalf("expected limit error")
+	}
+	if res.Total.Int64() != 65536 {
+		t.Fatalf("expected total to be reported, got %+v", res)
+	}
+	if !errors.Is(err, ErrRangeTooLarge) {
+		t.Fatalf("expected ErrRangeTooLarge, got %v", err)
+	}
+	var tooLarge *RangeTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected a *RangeTooLargeError, got %T", err)
+	}
+	if tooLarge.Count.Int64() != 65536 || tooLarge.Limit != 4096 || tooLarge.Prefix != netip.MustParsePrefix("140.82.0.0/16") {
+		t.Fatalf("unexpected error fields %+v", tooLarge)
+	}
+	if want := "CIDR too large to evaluate (65536 addresses exceeds the limit of 4096); a /20 would fit under the 4096 limit"; err.Error() != want {
+		t.Fatalf("error = %q, want %q", err, want)
+	}
+
+	if _, err := meta.EvaluatePrefix(netip.MustParsePrefix("140.82.0.0/16"), 65536); err != nil {
+		t.Fatalf("a prefix at the limit should be evaluated, got %v", err)
+	}
+}
+
+func TestFittingPrefixBits(t *testing.T) {
+	tests := []struct {
+		prefix string
+		limit  int
+		want   int
+	}{
+		{"140.82.0.0/16", 4096, 20},
+		{"140.82.0.0/16", 5000, 20},
+		{"140.82.0.0/16", 1, 32},
+		{"2001:db8::/48", 4096, 116},
+		{"0.0.0.0/0", 1 << 40, 0},
+		{"140.82.0.0/16", 0, -1},
+	}
+	for _, tt := range tests {
+		if got := FittingPrefixBits(netip.MustParsePrefix(tt.prefix), tt.limit); got != tt.want {
+			t.Errorf("FittingPrefixBits(%s, %d) = %d, want %d", tt.prefix, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixAddrs(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"192.0.2.4/30", []string{"192.0.2.4", "192.0.2.5", "192.0.2.6", "192.0.2.7"}},
+		{"2001:db8::/126", []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{"255.255.255.252/30", []string{"255.255.255.252", "255.255.255.253", "255.255.255.254", "255.255.255.255"}},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127", []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}},
+		{"192.0.2.9/32", []string{"192.0.2.9"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for addr := range PrefixAddrs(netip.MustParsePrefix(tt.prefix)) {
+			got = append(got, addr.String())
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("PrefixAddrs(%s) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	// Breaking out of the loop early must stop the iterator.
+	n := 0
+	for range PrefixAddrs(netip.MustParsePrefix("10.0.0.0/8")) {
+		if n++; n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Fatalf("expected to stop after 3 addresses, got %d", n)
+	}
+}
+
+func TestAddrToInt(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"192.30.252.42", "3223256106"},
+		{"0.0.0.0", "0"},
+		{"255.255.255.255", "4294967295"},
+		{"2001:db8::1", "42540766411282592856903984951653826561"},
+		{"::ffff:192.30.252.42", "281473904999466"},
+	}
+	for _, tt := range tests {
+		if got := AddrToInt(netip.MustParseAddr(tt.addr)); got.String() != tt.want {
+			t.Errorf("AddrToInt(%s) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+	if got := AddrToInt(netip.Addr{}); got != nil {
+		t.Errorf("AddrToInt(zero) = %s, want nil", got)
+	}
+}
+
+func TestAddrRange(t *testing.T) {
+	var got []string
+	for addr := range AddrRange(netip.MustParseAddr("10.0.0.254"), netip.MustParseAddr("10.0.1.1")) {
+		got = append(got, addr.String())
+	}
+	if strings.Join(got, ",") != "10.0.0.254,10.0.0.255,10.0.1.0,10.0.1.1" {
+		t.Fatalf("AddrRange yielded %v", got)
+	}
+	for range AddrRange(netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.1")) {
+		t.Fatal("expected nothing for a reversed range")
+	}
+	for range AddrRange(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::1")) {
+		t.Fatal("expected nothing for mixed families")
+	}
+}
@@ -0,0 +1,31 @@
+package githubmeta_test
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+func ExampleMetaData_EvaluatePrefix() {
+	meta, err := githubmeta.Parse(strings.NewReader(`{
+		"hooks": ["192.30.252.0/22"],
+		"api": ["192.30.252.0/24"]
+	}`))
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := meta.EvaluatePrefix(netip.MustParsePrefix("192.30.252.0/23"), 4096)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d of %s addresses owned\n", res.Owned, res.Total)
+	fmt.Println("api, hooks:", res.LabelSets["api, hooks"])
+	fmt.Println("hooks:", res.LabelSets["hooks"])
+	// Output:
+	// 512 of 512 addresses owned
+	// api, hooks: 256
+	// hooks: 256
+}
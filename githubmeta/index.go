@@ -0,0 +1,65 @@
+package githubmeta
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// prefixIndex finds the entries of one address family that contain an address by
+// binary search instead of a linear scan.
+//
+// Entries are sorted by network address and then by prefix length, so an entry is
+// preceded by every entry that encloses it. parent links each entry to the nearest
+// earlier entry enclosing it (-1 for none). The entries containing an address are
+// then all on the parent chain of the last entry whose network is at or below it:
+// walking that chain skips the siblings that ended before the address and stops
+// at the root.
+type prefixIndex struct {
+	entries []Entry
+	// networks holds the masked prefix of each entry.
+	networks []netip.Prefix
+	parent   []int
+}
+
+func newPrefixIndex(entries []Entry) prefixIndex {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return comparePrefixes(sorted[i].Prefix.Masked(), sorted[j].Prefix.Masked()) < 0
+	})
+	return linkPrefixIndex(sorted)
+}
+
+// linkPrefixIndex builds the index of entries already in index order.
+func linkPrefixIndex(sorted []Entry) prefixIndex {
+	networks := make([]netip.Prefix, len(sorted))
+	parent := make([]int, len(sorted))
+	var open []int
+	for i, entry := range sorted {
+		p := entry.Prefix.Masked()
+		networks[i] = p
+		// Close the entries that end before p starts; what remains encloses p.
+		for len(open) > 0 && !networks[open[len(open)-1]].Contains(p.Addr()) {
+			open = open[:len(open)-1]
+		}
+		parent[i] = -1
+		if len(open) > 0 {
+			parent[i] = open[len(open)-1]
+		}
+		open = append(open, i)
+	}
+	return prefixIndex{entries: sorted, networks: networks, parent: parent}
+}
+
+// containing calls yield for every entry whose prefix contains addr, most specific first.
+func (x prefixIndex) containing(addr netip.Addr, yield func(Entry)) {
+	// i is the last entry whose network address is not above addr.
+	i := sort.Search(len(x.networks), func(i int) bool {
+		return x.networks[i].Addr().Compare(addr) > 0
+	}) - 1
+	for ; i >= 0; i = x.parent[i] {
+		if x.networks[i].Contains(addr) {
+			yield(x.entries[i])
+		}
+	}
+}
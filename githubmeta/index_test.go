@@ -0,0 +1,110 @@
+package githubmeta
+
+import (
+	"math/rand"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// nestedMeta stacks several prefixes on the same addresses, including an identical
+// prefix under two labels and a sibling that ends just before a later lookup.
+const nestedMeta = `{
+  "web": ["10.0.0.0/8", "10.1.0.0/16", "2001:db8::/32"],
+  "api": ["10.1.2.0/24", "10.1.0.0/16", "2001:db8:1::/48"],
+  "hooks": ["10.1.2.128/25", "10.1.3.0/24", "10.2.0.0/16", "2001:db8:1:2::/64"],
+  "pages": ["10.1.2.128/25", "192.168.0.0/24"]
+}`
+
+func TestLookupNestedPrefixesMatchesLinearScan(t *testing.T) {
+	meta := loadFixture(t, nestedMeta)
+
+	cases := map[string]string{
+		"10.1.2.200":        "api,hooks,pages,web",
+		"10.1.2.1":          "api,web",
+		"10.1.3.1":          "api,hooks,web",
+		"10.1.4.1":          "api,web",
+		"10.3.0.0":          "web",
+		"9.255.255.255":     "",
+		"11.0.0.0":          "",
+		"192.168.0.255":     "pages",
+		"2001:db8:1:2::1":   "api,hooks,web",
+		"2001:db8:1:3::1":   "api,web",
+		"2001:db8:ffff::1":  "web",
+		"2001:db9::1":       "",
+		"::ffff:10.1.2.200": "",
+	}
+	for raw, want := range cases {
+		addr := netip.MustParseAddr(raw)
+		if got := strings.Join(meta.Lookup(addr), ","); got != want {
+			t.Errorf("Lookup(%s) = %q, want %q", raw, got, want)
+		}
+		if got, linear := meta.Lookup(addr), linearLookup(meta.Entries(), addr); strings.Join(got, ",") != strings.Join(linear, ",") {
+			t.Errorf("Lookup(%s) = %v, linear scan = %v", raw, got, linear)
+		}
+	}
+
+	entries := meta.LookupEntries(netip.MustParseAddr("10.1.2.200"))
+	if len(entries) != 6 || entries[0].Label != "api" || entries[len(entries)-1].Label != "web" {
+		t.Fatalf("LookupEntries should return every match in label order, got %v", entries)
+	}
+}
+
+func TestLookupRandomPrefixesMatchesLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	entries := randomEntries(rnd, 500)
+	meta := newMetaData(entries)
+
+	for i := 0; i < 5000; i++ {
+		addr := randomAddr4(rnd)
+		got, want := meta.Lookup(addr), linearLookup(entries, addr)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("Lookup(%s) = %v, linear scan = %v", addr, got, want)
+		}
+	}
+}
+
+func BenchmarkLookupIndexed(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	meta := newMetaData(randomEntries(rnd, 2000))
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		addrs[i] = randomAddr4(rnd)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		meta.Lookup(addrs[i%len(addrs)])
+	}
+}
+
+func BenchmarkLookupLinear(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	entries := randomEntries(rnd, 2000)
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		addrs[i] = randomAddr4(rnd)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearLookup(entries, addrs[i%len(addrs)])
+	}
+}
+
+// randomEntries returns n IPv4 entries of mixed lengths under a handful of labels.
+// Addresses are drawn from a few /8s so that prefixes nest and overlap often.
+func randomEntries(rnd *rand.Rand, n int) []Entry {
+	labels := []string{"actions", "api", "hooks", "pages", "web"}
+	entries := make([]Entry, n)
+	for i := range entries {
+		prefix := netip.PrefixFrom(randomAddr4(rnd), 8+rnd.Intn(25)).Masked()
+		entries[i] = Entry{Label: labels[rnd.Intn(len(labels))], Prefix: prefix}
+	}
+	return entries
+}
+
+// randomAddr4 returns an address in 10.0.0.0/8 through 13.255.255.255.
+func randomAddr4(rnd *rand.Rand) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(10 + rnd.Intn(4)), byte(rnd.Intn(4)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+}
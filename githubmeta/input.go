@@ -0,0 +1,76 @@
+package githubmeta
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParseReason is a machine-readable code explaining why an input is not a valid
+// IP address or CIDR prefix.
+type ParseReason string
+
+const (
+	// ReasonEmpty means the input was blank.
+	ReasonEmpty ParseReason = "empty"
+	// ReasonNotAnIP means the input does not resemble an address at all.
+	ReasonNotAnIP ParseReason = "not_an_ip"
+	// ReasonBadOctet means a dotted-quad address has a component above 255.
+	ReasonBadOctet ParseReason = "bad_octet"
+	// ReasonBadIPv4 means the input looks like IPv4 but is malformed otherwise,
+	// e.g. the wrong number of octets or leading zeros.
+	ReasonBadIPv4 ParseReason = "bad_ipv4"
+	// ReasonBadIPv6 means the input looks like IPv6 but is malformed.
+	ReasonBadIPv6 ParseReason = "bad_ipv6"
+	// ReasonBadCIDRBits means the prefix length is not a number or is too long
+	// for the address family.
+	ReasonBadCIDRBits ParseReason = "bad_cidr_bits"
+)
+
+// ClassifyInput returns the reason raw cannot be parsed as an address (or as a
+// prefix when it contains a slash). It returns "" when raw parses successfully.
+func ClassifyInput(raw string) ParseReason {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ReasonEmpty
+	}
+
+	addrPart, bitsPart, isPrefix := strings.Cut(raw, "/")
+	addr, err := netip.ParseAddr(addrPart)
+	if err != nil {
+		return classifyAddr(addrPart)
+	}
+	if !isPrefix {
+		return ""
+	}
+
+	bits, err := strconv.Atoi(bitsPart)
+	if err != nil || bits < 0 || bits > addr.BitLen() {
+		return ReasonBadCIDRBits
+	}
+	if _, err := netip.ParsePrefix(raw); err != nil {
+		return ReasonNotAnIP
+	}
+	return ""
+}
+
+// classifyAddr explains why s, which failed netip.ParseAddr, is not an address.
+func classifyAddr(s string) ParseReason {
+	if strings.Contains(s, ":") {
+		if strings.Trim(s, "0123456789abcdefABCDEF:.%") == "" || strings.Contains(s, "%") {
+			return ReasonBadIPv6
+		}
+		return ReasonNotAnIP
+	}
+	if s == "" || strings.Trim(s, "0123456789.") != "" || !strings.Contains(s, ".") {
+		return ReasonNotAnIP
+	}
+
+	parts := strings.Split(s, ".")
+	for _, part := range parts {
+		if n, err := strconv.Atoi(part); err == nil && n > 255 {
+			return ReasonBadOctet
+		}
+	}
+	return ReasonBadIPv4
+}
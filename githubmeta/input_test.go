@@ -0,0 +1,39 @@
+package githubmeta
+
+import "testing"
+
+func TestClassifyInput(t *testing.T) {
+	cases := map[string]ParseReason{
+		"192.168.1.256":      ReasonBadOctet,
+		"not-an-ip":          ReasonNotAnIP,
+		"10.0.0.0/40":        ReasonBadCIDRBits,
+		"10.0.0.0/abc":       ReasonBadCIDRBits,
+		"2001:db8::/129":     ReasonBadCIDRBits,
+		"10.0.0":             ReasonBadIPv4,
+		"010.0.0.1":          ReasonBadIPv4,
+		"2001:db8:::1":       ReasonBadIPv6,
+		"host:name":          ReasonNotAnIP,
+		"   ":                ReasonEmpty,
+		"192.168.1.1":        "",
+		"192.30.252.0/22":    "",
+		"2001:db8::1":        "",
+		"192.168.1.999/24":   ReasonBadOctet,
+		"2001:db8::/64":      "",
+		"192.30.252.42/22":   "",
+		"1.2.3.4.5":          ReasonBadIPv4,
+		"12345":              ReasonNotAnIP,
+		"ff02::1%eth0/64":    ReasonNotAnIP,
+		"2001:db8::zz":       ReasonNotAnIP,
+		"2001:db8::1%eth0":   "",
+		"fe80::1%":           ReasonBadIPv6,
+		"10.0.0.0/-1":        ReasonBadCIDRBits,
+		"10.0.0.0/":          ReasonBadCIDRBits,
+		"2001:db8::/":        ReasonBadCIDRBits,
+		"256.256.256.256/16": ReasonBadOctet,
+	}
+	for in, want := range cases {
+		if got := ClassifyInput(in); got != want {
+			t.Errorf("ClassifyInput(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
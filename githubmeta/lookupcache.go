@@ -0,0 +1,69 @@
+package githubmeta
+
+import (
+	"container/list"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// WithLookupCache returns a copy of m whose Lookup memoizes results for up to size
+// addresses, evicting the least recently used. The copy is safe for concurrent use
+// and suits servers and batch jobs that see the same addresses repeatedly. A size
+// below 1 returns a copy without a cache.
+func (m *MetaData) WithLookupCache(size int) *MetaData {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	c.cache = nil
+	if size > 0 {
+		c.cache = &lookupCache{size: size, order: list.New(), items: make(map[netip.Addr]*list.Element)}
+	}
+	return &c
+}
+
+// lookupCache is a fixed-size LRU of Lookup results keyed by address.
+type lookupCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // of *lookupCacheItem, most recently used first
+	items map[netip.Addr]*list.Element
+}
+
+type lookupCacheItem struct {
+	addr   netip.Addr
+	labels []string
+}
+
+func (c *lookupCache) get(addr netip.Addr) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[addr]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return slices.Clone(elem.Value.(*lookupCacheItem).labels), true
+}
+
+func (c *lookupCache) add(addr netip.Addr, labels []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[addr]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[addr] = c.order.PushFront(&lookupCacheItem{addr: addr, labels: slices.Clone(labels)})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lookupCacheItem).addr)
+	}
+}
+
+func (c *lookupCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
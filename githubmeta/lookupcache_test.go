@@ -0,0 +1,80 @@
+package githubmeta
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestWithLookupCacheMatchesUncachedLookup(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+	cached := meta.WithLookupCache(2)
+
+	addrs := []string{"192.30.252.1", "140.82.112.9", "8.8.8.8", "192.30.252.1", "2001:db8:1::1", "140.82.112.9", "192.30.252.1"}
+	for _, raw := range addrs {
+		addr := netip.MustParseAddr(raw)
+		if got, want := cached.Lookup(addr), meta.Lookup(addr); !slices.Equal(got, want) {
+			t.Fatalf("Lookup(%s) = %v with cache, %v without", raw, got, want)
+		}
+	}
+	if n := cached.cache.len(); n != 2 {
+		t.Fatalf("expected the cache to hold 2 entries, got %d", n)
+	}
+
+	// Mutating a returned slice must not leak into later results.
+	addr := netip.MustParseAddr("192.30.252.1")
+	cached.Lookup(addr)[0] = "mutated"
+	if got := cached.Lookup(addr); got[0] != "api" {
+		t.Fatalf("cached result was mutated: %v", got)
+	}
+	if meta.cache != nil {
+		t.Fatal("WithLookupCache must not modify the receiver")
+	}
+}
+
+func TestWithLookupCacheConcurrentUse(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+	cached := meta.WithLookupCache(8)
+	addrs := []netip.Addr{
+		netip.MustParseAddr("192.30.252.1"),
+		netip.MustParseAddr("192.30.254.1"),
+		netip.MustParseAddr("140.82.112.9"),
+		netip.MustParseAddr("185.199.108.153"),
+		netip.MustParseAddr("8.8.8.8"),
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				addr := addrs[i%len(addrs)]
+				if got, want := cached.Lookup(addr), meta.Lookup(addr); !slices.Equal(got, want) {
+					t.Errorf("Lookup(%s) = %v, want %v", addr, got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWithLookupCacheZeroLeavesSharedCacheAlone(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+	cached := meta.WithLookupCache(8)
+	hot := netip.MustParseAddr("140.82.112.9")
+	cached.Lookup(hot)
+
+	// Iterating a prefix on an uncached copy must not churn the shared cache.
+	if _, err := cached.WithLookupCache(0).EvaluatePrefix(netip.MustParsePrefix("192.30.252.0/24"), 256); err != nil {
+		t.Fatal(err)
+	}
+	if n := cached.cache.len(); n != 1 {
+		t.Fatalf("expected only the hot entry in the cache, got %d entries", n)
+	}
+	if _, ok := cached.cache.get(hot); !ok {
+		t.Fatal("the hot entry was evicted")
+	}
+}
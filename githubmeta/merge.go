@@ -0,0 +1,39 @@
+package githubmeta
+
+// MergeMeta combines the entries of several meta documents, such as github.com's and
+// a GitHub Enterprise Server's, keyed by the name each should be attributed to. Every
+// entry's Source is set to that name unless it already has one, so merging merged
+// data keeps the original attribution. Lookups on the result see every document's
+// ranges; the non-CIDR fields of the documents are not carried over.
+func MergeMeta(sources map[string]*MetaData) *MetaData {
+	var entries []Entry
+	for name, meta := range sources {
+		for _, entry := range meta.Entries() {
+			if entry.Source == "" {
+				entry.Source = name
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sortEntries(entries)
+	return newMetaData(entries)
+}
+
+// TagSources returns a copy of m in which every entry with a Source is labelled
+// "label@source", e.g. "web@github.com", so that lookups, label counts and CIDR
+// summaries attribute each range to the document it came from.
+func (m *MetaData) TagSources() *MetaData {
+	if m == nil {
+		return nil
+	}
+	entries := m.Entries()
+	for i, entry := range entries {
+		if entry.Source != "" {
+			entries[i].Label = entry.Label + "@" + entry.Source
+		}
+	}
+	sortEntries(entries)
+	tagged := newMetaData(entries)
+	tagged.extra = m.extra
+	return tagged
+}
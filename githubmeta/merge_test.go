@@ -0,0 +1,48 @@
+package githubmeta
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// enterpriseMeta publishes a web range overlapping fixtureMeta's and an api range of
+// its own.
+const enterpriseMeta = `{
+  "web": ["140.82.112.0/24", "10.0.0.0/24"],
+  "api": ["10.0.1.0/24"]
+}`
+
+func TestMergeMetaAttributesSources(t *testing.T) {
+	merged := MergeMeta(map[string]*MetaData{
+		"github.com":   loadFixture(t, fixtureMeta),
+		"ghes.example": loadFixture(t, enterpriseMeta),
+	})
+
+	if got := strings.Join(merged.Lookup(netip.MustParseAddr("10.0.1.1")), ","); got != "api" {
+		t.Fatalf("Lookup in merged meta = %q, want api", got)
+	}
+	for _, entry := range merged.LookupEntries(netip.MustParseAddr("140.82.112.5")) {
+		if entry.Source == "" {
+			t.Fatalf("merged entry %+v has no source", entry)
+		}
+	}
+
+	tagged := merged.TagSources()
+	if got := strings.Join(tagged.Lookup(netip.MustParseAddr("140.82.112.5")), ","); got != "web@ghes.example,web@github.com" {
+		t.Fatalf("tagged Lookup = %q", got)
+	}
+	summary, err := tagged.EvaluatePrefix(netip.MustParsePrefix("140.82.112.0/23"), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"web@ghes.example, web@github.com": 256, "web@github.com": 256}
+	if len(summary.LabelSets) != len(want) {
+		t.Fatalf("label sets = %v, want %v", summary.LabelSets, want)
+	}
+	for set, n := range want {
+		if summary.LabelSets[set] != n {
+			t.Fatalf("label sets = %v, want %v", summary.LabelSets, want)
+		}
+	}
+}
@@ -0,0 +1,1036 @@
+package githubmeta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const metaURL = "https://api.github.com/meta"
+
+var metaEndpoint = metaURL
+
+// secondaryRateLimitBackoff is how long fetch waits before its single retry after
+// GitHub's abuse-detection (secondary) rate limit rejects a request.
+var secondaryRateLimitBackoff = 60 * time.Second
+
+// ErrSecondaryRateLimited is returned when GitHub's secondary rate limit rejects the
+// request and the retry after backing off is rejected too (or cannot be attempted).
+var ErrSecondaryRateLimited = errors.New("github secondary rate limit exceeded")
+
+// DefaultMaxResponseBytes is the largest meta response body read when
+// FetchOptions.MaxResponseBytes is zero. The real document is well under 1 MiB.
+const DefaultMaxResponseBytes = 32 << 20
+
+// ErrResponseTooLarge is returned when the meta response body exceeds the configured cap.
+var ErrResponseTooLarge = errors.New("meta response too large")
+
+// ErrNonJSONResponse is returned when the meta endpoint answers 200 with a body
+// whose Content-Type is not JSON, such as a captive portal's login page.
+var ErrNonJSONResponse = errors.New("meta response is not JSON")
+
+// ErrNoEntries is returned by a fetch with FetchOptions.StrictEntries set when the
+// response parses but holds no CIDRs, which the real endpoint never serves.
+var ErrNoEntries = errors.New("no CIDR entries found in meta response")
+
+// ErrUnexpectedMetaShape is returned when a meta document is valid JSON but not an
+// object, which usually means the URL points at a different API.
+var ErrUnexpectedMetaShape = errors.New("meta response is not a JSON object")
+
+// SchemaVersionHeader is the response header fetch reads the meta schema version
+// from. A document may instead carry it in a top-level "schema_version" string.
+const SchemaVersionHeader = "X-GitHub-Meta-Version"
+
+// schemaVersionField is the document field consulted when the header is absent.
+const schemaVersionField = "schema_version"
+
+// knownSchemaVersions are the schema versions this parser understands; the empty
+// string is today's unversioned document. Fetching any other version still parses
+// it as best it can but warns that the results may be incomplete.
+var knownSchemaVersions = map[string]bool{"": true, "1": true}
+
+// ErrChecksumMismatch is returned when a payload's SHA-256 differs from the checksum
+// recorded in the cache or pinned with FetchOptions.ExpectSHA256.
+var ErrChecksumMismatch = errors.New("meta payload checksum mismatch")
+
+// Entry describes a single CIDR block tagged with the GitHub subsystem it belongs to.
+type Entry struct {
+	Label  string
+	Prefix netip.Prefix
+	// Source names the document the entry came from in a MergeMeta result, such as
+	// "github.com"; it is empty otherwise.
+	Source string
+}
+
+// MetaData contains all CIDR entries from the GitHub meta endpoint and offers lookup utilities.
+type MetaData struct {
+	entries []Entry
+	// entries4 and entries6 partition entries by address family so Lookup only
+	// scans prefixes that can possibly contain the address.
+	entries4 []Entry
+	entries6 []Entry
+	// index4 and index6 answer Lookup by binary search; see prefixIndex.
+	index4 prefixIndex
+	index6 prefixIndex
+	// cache memoizes Lookup results; see WithLookupCache.
+	cache *lookupCache
+	// extra holds the fields of the parsed document that carry no CIDRs, such as
+	// ssh_keys, so MarshalJSON can reproduce them.
+	extra map[string]json.RawMessage
+	// etag is the ETag the payload was served or cached with; see ETag.
+	etag string
+	// source records where the payload came from; see Source.
+	source Source
+	// schemaVersion is the declared schema version; see SchemaVersion.
+	schemaVersion string
+	// duplicates are the entries Parse dropped as repeats; Validate reports them.
+	duplicates []Entry
+	// raw is the JSON document m was parsed from; see RawJSON.
+	raw []byte
+}
+
+// Source says where a MetaData payload was read from.
+type Source string
+
+const (
+	// SourceNetwork is a fresh response from the meta endpoint.
+	SourceNetwork Source = "network"
+	// SourceCache is the on-disk cache, after a 304 or in place of a failed request.
+	SourceCache Source = "cache"
+	// SourceEmbedded is the snapshot bundled with the package.
+	SourceEmbedded Source = "embedded"
+	// SourceFile is a document read with LoadFromFile.
+	SourceFile Source = "file"
+)
+
+// FetchOptions configures FetchWithOptions.
+type FetchOptions struct {
+	// Client performs the request; nil uses a client whose transport honors the
+	// standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables.
+	Client *http.Client
+	// CacheDir holds the on-disk cache. Empty disables caching; DefaultCacheDir
+	// returns the location Fetch uses.
+	CacheDir string
+	// UseDefaultCacheDir caches under DefaultCacheDir when CacheDir is empty. If no
+	// default can be determined, caching is disabled and Warnf is told why.
+	UseDefaultCacheDir bool
+	// ReadOnlyCache uses an existing cache for ETag revalidation and fallback but
+	// never writes to it, for read-only or pre-seeded cache directories.
+	ReadOnlyCache bool
+	// Warnf, when set, receives non-fatal problems such as caching being disabled.
+	Warnf func(format string, args ...any)
+	// EmbeddedFallback serves EmbeddedMeta, with a staleness warning through Warnf,
+	// when neither the network nor the cache can provide the meta data. It is never
+	// used to paper over a checksum mismatch or a cancelled or expired context.
+	EmbeddedFallback bool
+	// ExpectSHA256, when set, pins the payload to a known-good hex SHA-256: a
+	// response or cached copy with any other checksum is rejected with
+	// ErrChecksumMismatch.
+	ExpectSHA256 string
+	// MaxResponseBytes caps the response body; a larger one fails with
+	// ErrResponseTooLarge. Zero means DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+	// StrictEntries rejects a response without a single CIDR with ErrNoEntries.
+	// GitHub's own endpoint always publishes ranges, so an empty document from it
+	// points at a broken proxy or endpoint; leave it unset for servers whose meta
+	// legitimately carries none, such as a GitHub Enterprise Server with only
+	// ssh_keys. Fetch and FetchWithCacheDir set it.
+	StrictEntries bool
+	// RequestFunc, when set, is called with every request to the meta endpoint after
+	// the default headers are set, so embedders can add tracing or authentication
+	// headers. The request keeps the fetch's context whatever RequestFunc does.
+	RequestFunc func(*http.Request)
+	// ConnectTimeout, when positive and Client is nil, bounds establishing the
+	// connection separately from the overall deadline carried by the context, so a
+	// dead host fails fast while a slow download may still finish.
+	ConnectTimeout time.Duration
+	// Endpoint, when set, is requested instead of https://api.github.com/meta, for
+	// a mirror or a GitHub Enterprise Server's /api/v3/meta. The cache does not
+	// record which endpoint filled it, so give each endpoint its own CacheDir.
+	Endpoint string
+	// Proxy, when set and Client is nil, routes every request through this proxy
+	// instead of the one named by the environment.
+	Proxy *url.URL
+	// PinResolvedIP records in the cache directory the address the endpoint's host
+	// resolved to on each successful connection. When resolving the host later
+	// fails, the request is retried once against that address, keeping the host
+	// name for the Host header and TLS verification, before falling back to the
+	// cache. It needs a cache directory and a Client whose Transport is an
+	// *http.Transport (or nil).
+	PinResolvedIP bool
+	// Tracef, when set, receives one line for each decision the fetch makes about
+	// the cache: the ETag sent, the response status, whether the payload was saved
+	// and whether the cache or the embedded snapshot stood in for the network.
+	Tracef func(format string, args ...any)
+	// Families, when set, keeps only the prefixes of these address families. The
+	// others are dropped while parsing, saving the memory and lookup time they would
+	// cost; the cache still stores the whole document.
+	Families Families
+	// PreferCache serves a cached copy younger than CacheTTL without contacting the
+	// endpoint at all, instead of revalidating it with a conditional request. Only an
+	// absent, stale or unreadable cache leads to a request, which then behaves as
+	// usual.
+	PreferCache bool
+	// CacheTTL is how long a cached copy counts as fresh for PreferCache; zero means
+	// DefaultCacheTTL. A copy is as old as its last download or 304 revalidation.
+	CacheTTL time.Duration
+}
+
+// DefaultCacheTTL is the FetchOptions.CacheTTL used when none is set. GitHub changes
+// its ranges rarely, so an hour-old copy is almost always current.
+const DefaultCacheTTL = time.Hour
+
+// Fetch downloads the GitHub meta endpoint and parses the CIDR information.
+// If ctx is already done when Fetch is called, no request is made: a previously
+// cached payload is served when one exists, and otherwise the context's error is
+// returned. When neither the network nor the cache is usable for any other reason,
+// the bundled EmbeddedMeta snapshot is returned instead.
+func Fetch(ctx context.Context, client *http.Client) (*MetaData, error) {
+	return FetchWithOptions(ctx, FetchOptions{Client: client, UseDefaultCacheDir: true, EmbeddedFallback: true, StrictEntries: true})
+}
+
+// FetchWithCacheDir downloads the GitHub meta endpoint using a user-provided cache directory.
+// An empty cacheDir disables on-disk caching.
+func FetchWithCacheDir(ctx context.Context, client *http.Client, cacheDir string) (*MetaData, error) {
+	return FetchWithOptions(ctx, FetchOptions{Client: client, CacheDir: cacheDir, StrictEntries: true})
+}
+
+// FetchWithOptions downloads the GitHub meta endpoint as configured by opts.
+func FetchWithOptions(ctx context.Context, opts FetchOptions) (*MetaData, error) {
+	cfg, store := opts.setup()
+	meta, err := fetch(ctx, cfg, store)
+	// A cancelled or expired context means the caller gave up, which the stale
+	// snapshot must not paper over.
+	if err != nil && opts.EmbeddedFallback && !errors.Is(err, ErrChecksumMismatch) && !contextDone(ctx, err) {
+		if cfg.pin != "" && payloadSHA256(embeddedMetaJSON) != cfg.pin {
+			return nil, err
+		}
+		embedded, embeddedErr := embeddedMeta(opts.Families)
+		if embeddedErr != nil {
+			return nil, err
+		}
+		cfg.trace("no usable response or cache → served the bundled snapshot")
+		opts.warnf("%v; using the bundled meta data snapshot, which may be stale", err)
+		return embedded, nil
+	}
+	return meta, err
+}
+
+// contextDone reports whether err is, or was caused by, the end of ctx.
+func contextDone(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// CheckForUpdate reports whether the meta endpoint has a different document from
+// the one cached under the options' cache directory. It sends a HEAD request with
+// the cached ETag and neither downloads, parses nor caches the document: 304 means
+// up to date and 200 means an update is available. Without a cached ETag there is
+// nothing to compare against, so an update is reported. Fallbacks do not apply; a
+// failed request is returned as an error.
+func CheckForUpdate(ctx context.Context, opts FetchOptions) (bool, error) {
+	cfg, store := opts.setup()
+	etag := store.readETag()
+	if etag == "" {
+		cfg.trace("no cached ETag; reporting an update")
+		return true, nil
+	}
+	if cfg.client == nil {
+		cfg.client = defaultClient
+	}
+	req, err := cfg.newRequest(ctx, http.MethodHead, etag)
+	if err != nil {
+		return false, err
+	}
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("check github meta: %w", err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		cfg.trace("304 → up to date with ETag %s", etag)
+		return false, nil
+	case http.StatusOK:
+		cfg.trace("200 → the document no longer matches ETag %s", etag)
+		return true, nil
+	default:
+		return false, fmt.Errorf("unexpected status %d from meta endpoint", resp.StatusCode)
+	}
+}
+
+// VerifyCache compares the copy cached under the options' cache directory with a
+// fresh download, as a manual integrity check: Added lists the live entries missing
+// from the cache and Removed the cached entries no longer published. The request
+// carries no ETag, the response is not saved and no fallback applies, so a failed
+// request is returned as an error. It also fails when nothing is cached.
+func VerifyCache(ctx context.Context, opts FetchOptions) (MetaDiff, error) {
+	cfg, store := opts.setup()
+	if store == nil {
+		return MetaDiff{}, errors.New("verify cache: caching is disabled")
+	}
+	cached, err := store.load()
+	if err != nil {
+		return MetaDiff{}, fmt.Errorf("verify cache: read %s: %w", store.dir, err)
+	}
+	if cfg.client == nil {
+		cfg.client = defaultClient
+	}
+	cfg.trace("requesting the full document to compare with %s", store.metaPath())
+	live, err := fetchOnce(ctx, cfg, nil, "")
+	if err != nil {
+		return MetaDiff{}, err
+	}
+	return DiffMeta(cached, live), nil
+}
+
+// setup derives the fetch configuration and cache store from o.
+func (o FetchOptions) setup() (fetchConfig, *cacheStore) {
+	cacheDir := o.CacheDir
+	if cacheDir == "" && o.UseDefaultCacheDir {
+		cacheDir = resolveDefaultCacheDir(o.warnf)
+	}
+	pin := strings.ToLower(strings.TrimSpace(o.ExpectSHA256))
+	store := newCacheStore(cacheDir)
+	if store != nil {
+		store.readOnly = o.ReadOnlyCache
+		store.warnf = o.warnf
+		store.pin = pin
+		store.families = o.Families
+	}
+	cfg := fetchConfig{client: o.Client, endpoint: o.Endpoint, pin: pin, maxBytes: o.MaxResponseBytes, strict: o.StrictEntries, requestFunc: o.RequestFunc, pinResolvedIP: o.PinResolvedIP, families: o.Families, warnf: o.warnf, tracef: o.Tracef}
+	if o.PreferCache {
+		cfg.cacheTTL = o.CacheTTL
+		if cfg.cacheTTL <= 0 {
+			cfg.cacheTTL = DefaultCacheTTL
+		}
+	}
+	if cfg.maxBytes <= 0 {
+		cfg.maxBytes = DefaultMaxResponseBytes
+	}
+	if cfg.client == nil && (o.ConnectTimeout > 0 || o.Proxy != nil) {
+		cfg.client = o.HTTPClient()
+	}
+	if cfg.endpoint == "" {
+		cfg.endpoint = metaEndpoint
+	}
+	return cfg, store
+}
+
+func (o FetchOptions) warnf(format string, args ...any) {
+	if o.Warnf != nil {
+		o.Warnf(format, args...)
+	}
+}
+
+// LoadFromFile parses a meta.json document stored on disk, such as an archived snapshot
+// of the GitHub meta endpoint.
+func LoadFromFile(path string) (*MetaData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	meta, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	meta.source = SourceFile
+	return meta, nil
+}
+
+// Parse reads a meta.json document from r. Fields other than the CIDR labels, such
+// as ssh_keys and domains, are kept for MarshalJSON. A document without any CIDRs
+// parses to a MetaData with no entries, on which every Lookup misses.
+func Parse(r io.Reader) (*MetaData, error) {
+	return parse(r, AllFamilies)
+}
+
+// parse is Parse keeping only the prefixes of families.
+func parse(r io.Reader, families Families) (*MetaData, error) {
+	doc, err := parseMetaDocument(r, families)
+	if err != nil {
+		return nil, err
+	}
+	m := newMetaData(doc.entries)
+	m.extra = doc.extra
+	m.duplicates = doc.duplicates
+	m.raw = doc.raw
+	if v, ok := doc.extra[schemaVersionField]; ok {
+		_ = json.Unmarshal(v, &m.schemaVersion)
+	}
+	return m, nil
+}
+
+// parseMetaJSON converts the JSON response into a slice of entries.
+func parseMetaJSON(r io.Reader) ([]Entry, error) {
+	doc, err := parseMetaDocument(r, AllFamilies)
+	return doc.entries, err
+}
+
+// metaDocument is a parsed meta.json.
+type metaDocument struct {
+	// entries holds one entry per label and masked prefix, sorted by sortEntries.
+	entries []Entry
+	// duplicates holds the entries dropped from entries because the same prefix,
+	// possibly spelled with host bits, was already listed under the same label.
+	duplicates []Entry
+	// raw is the document itself.
+	raw []byte
+	// extra holds the raw value of every field that yielded no CIDR entries.
+	extra map[string]json.RawMessage
+}
+
+// parseMetaDocument parses a meta.json document, dropping the prefixes outside
+// families. GitHub occasionally lists a prefix twice under one label; only the first
+// of each is kept, so counts and lookups are not inflated, while the rest are
+// recorded for Validate.
+func parseMetaDocument(r io.Reader, families Families) (metaDocument, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return metaDocument{}, fmt.Errorf("decode meta response: %w", err)
+	}
+	if kind := jsonKind(raw); kind != "object" {
+		return metaDocument{}, fmt.Errorf("%w: got a JSON %s; check that the endpoint is GitHub's /meta API", ErrUnexpectedMetaShape, kind)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return metaDocument{}, fmt.Errorf("decode meta response: %w", err)
+	}
+
+	var all []Entry
+	doc := metaDocument{raw: raw, extra: make(map[string]json.RawMessage)}
+	for label, value := range fields {
+		// A label whose prefixes are all of a dropped family is still a CIDR field.
+		isCIDRs := false
+		if cidrs, ok := extractStringSlice(value); ok {
+			for _, cidr := range cidrs {
+				prefix, err := netip.ParsePrefix(cidr)
+				if err != nil {
+					continue
+				}
+				isCIDRs = true
+				if families.Contains(prefix.Addr()) {
+					all = append(all, Entry{Label: label, Prefix: prefix})
+				}
+			}
+		}
+		if !isCIDRs {
+			doc.extra[label] = value
+		}
+	}
+
+	sortEntries(all)
+	seen := make(map[Entry]bool, len(all))
+	for _, entry := range all {
+		key := Entry{Label: entry.Label, Prefix: entry.Prefix.Masked()}
+		if seen[key] {
+			doc.duplicates = append(doc.duplicates, entry)
+			continue
+		}
+		seen[key] = true
+		doc.entries = append(doc.entries, entry)
+	}
+	return doc, nil
+}
+
+// sortEntries orders entries by label and then prefix, the order Entries reports.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Label != entries[j].Label {
+			return entries[i].Label < entries[j].Label
+		}
+		if a, b := entries[i].Prefix.String(), entries[j].Prefix.String(); a != b {
+			return a < b
+		}
+		return entries[i].Source < entries[j].Source
+	})
+}
+
+// jsonKind names the type of the JSON value v, which must be valid JSON.
+func jsonKind(v json.RawMessage) string {
+	switch bytes.TrimSpace(v)[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 'n':
+		return "null"
+	case 't', 'f':
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+func extractStringSlice(value json.RawMessage) ([]string, bool) {
+	var out []string
+	if err := json.Unmarshal(value, &out); err != nil || out == nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func newMetaData(entries []Entry) *MetaData {
+	copyEntries := make([]Entry, len(entries))
+	copy(copyEntries, entries)
+
+	m := &MetaData{entries: copyEntries}
+	for _, entry := range copyEntries {
+		if entry.Prefix.Addr().Is4() {
+			m.entries4 = append(m.entries4, entry)
+		} else {
+			m.entries6 = append(m.entries6, entry)
+		}
+	}
+	m.index4 = newPrefixIndex(m.entries4)
+	m.index6 = newPrefixIndex(m.entries6)
+	return m
+}
+
+// Entries exposes a copy of the parsed entries.
+func (m *MetaData) Entries() []Entry {
+	if m == nil {
+		return nil
+	}
+	out := make([]Entry, len(m.entries))
+	copy(out, m.entries)
+	return out
+}
+
+// PrefixCount returns the number of entries and how many of them are IPv4 and IPv6,
+// without copying them as len(Entries()) would.
+func (m *MetaData) PrefixCount() (total, v4, v6 int) {
+	if m == nil {
+		return 0, 0, 0
+	}
+	return len(m.entries), len(m.entries4), len(m.entries6)
+}
+
+// Labels returns the distinct labels that publish at least one prefix, sorted.
+func (m *MetaData) Labels() []string {
+	if m == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var labels []string
+	for _, entry := range m.entries {
+		if !seen[entry.Label] {
+			seen[entry.Label] = true
+			labels = append(labels, entry.Label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// ResolveLabel returns the label matching name, spelled as in the document, and
+// whether there is one. Label arguments throughout MetaData ignore case this way,
+// since custom and GitHub Enterprise Server documents do not always use GitHub's
+// lowercase snake_case; results keep the document's spelling.
+func (m *MetaData) ResolveLabel(name string) (string, bool) {
+	for _, label := range m.Labels() {
+		if strings.EqualFold(label, name) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// CompleteLabel returns the labels that start with prefix, ignoring case, sorted; an
+// empty prefix returns every label. It backs label completion in interactive front ends.
+func (m *MetaData) CompleteLabel(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	var out []string
+	for _, label := range m.Labels() {
+		if strings.HasPrefix(strings.ToLower(label), prefix) {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+// FilterLabels returns a copy of m holding only the entries of the given labels.
+// Fields without CIDRs are kept, so MarshalJSON still produces a complete document.
+func (m *MetaData) FilterLabels(labels ...string) *MetaData {
+	if m == nil {
+		return nil
+	}
+	keep := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		keep[strings.ToLower(label)] = true
+	}
+	var entries []Entry
+	for _, entry := range m.entries {
+		if keep[strings.ToLower(entry.Label)] {
+			entries = append(entries, entry)
+		}
+	}
+	filtered := newMetaData(entries)
+	filtered.extra = m.extra
+	return filtered
+}
+
+// RunnerLabels returns the labels of the ranges GitHub-hosted runners and codespaces
+// connect from, which firewalls in front of self-hosted services have to admit:
+// actions, actions_macos and codespaces. Pass them to FilterLabels.
+func RunnerLabels() []string {
+	return []string{"actions", "actions_macos", "codespaces"}
+}
+
+// Families is a set of address families, for FetchOptions.Families and
+// FilterFamilies.
+type Families uint8
+
+const (
+	// IPv4 and IPv6 each hold a single family.
+	IPv4 Families = 1 << iota
+	IPv6
+	// AllFamilies, like the zero value, is both IPv4 and IPv6.
+	AllFamilies = IPv4 | IPv6
+)
+
+// Contains reports whether addr's family is in f. The zero Families contains both.
+func (f Families) Contains(addr netip.Addr) bool {
+	if f == 0 {
+		f = AllFamilies
+	}
+	return (addr.Is4() && f&IPv4 != 0) || (addr.Is6() && f&IPv6 != 0)
+}
+
+// FilterFamilies returns a copy of m keeping only the entries of the given families.
+// To avoid parsing the other family at all, set FetchOptions.Families instead.
+func (m *MetaData) FilterFamilies(families Families) *MetaData {
+	if m == nil {
+		return nil
+	}
+	var entries []Entry
+	for _, entry := range m.entries {
+		if families.Contains(entry.Prefix.Addr()) {
+			entries = append(entries, entry)
+		}
+	}
+	filtered := newMetaData(entries)
+	filtered.extra = m.extra
+	return filtered
+}
+
+// FilterPrefixLen returns a copy of m keeping only the entries whose prefix length
+// is at least min and at most max, so broader or narrower blocks match nothing. The
+// bounds are compared with each prefix's length within its own family: a /24 bound
+// applies to IPv4 /24s and IPv6 /24s alike. A bound of zero or less is not enforced.
+func (m *MetaData) FilterPrefixLen(min, max int) *MetaData {
+	if m == nil {
+		return nil
+	}
+	var entries []Entry
+	for _, entry := range m.entries {
+		bits := entry.Prefix.Bits()
+		if (min > 0 && bits < min) || (max > 0 && bits > max) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	filtered := newMetaData(entries)
+	filtered.extra = m.extra
+	return filtered
+}
+
+// MarshalJSON reconstructs a meta.json-shaped document: every label maps to its
+// prefixes, and the fields without CIDRs that Parse kept (ssh_keys, domains and so
+// on) are reproduced verbatim. Unparsable CIDR strings are not preserved.
+func (m *MetaData) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]any)
+	if m != nil {
+		for key, value := range m.extra {
+			doc[key] = value
+		}
+		byLabel := make(map[string][]netip.Prefix)
+		for _, entry := range m.entries {
+			byLabel[entry.Label] = append(byLabel[entry.Label], entry.Prefix)
+		}
+		for label, prefixes := range byLabel {
+			doc[label] = prefixes
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// ETag returns the entity tag of the payload m was parsed from: the one sent by the
+// meta endpoint, or the one stored alongside a cached copy (also reported by
+// InspectCache). It is empty for documents read with Parse or LoadFromFile and for
+// responses without an ETag header.
+func (m *MetaData) ETag() string {
+	if m == nil {
+		return ""
+	}
+	return m.etag
+}
+
+// SchemaVersion reports the meta schema version declared by the SchemaVersionHeader
+// response header or, failing that, the document's "schema_version" field. It is
+// empty for the unversioned document GitHub serves today. A copy served from the
+// cache only knows the version recorded in the document itself.
+func (m *MetaData) SchemaVersion() string {
+	if m == nil {
+		return ""
+	}
+	return m.schemaVersion
+}
+
+// RawJSON returns a copy of the JSON document m was parsed from, as the endpoint
+// served it, the cache stored it or the file held it, for debugging the parser. It
+// keeps the prefixes FetchOptions.Families dropped. It is nil for MetaData that was
+// not parsed from one document, such as the results of FilterLabels, MergeMeta and
+// Decode.
+func (m *MetaData) RawJSON() []byte {
+	if m == nil {
+		return nil
+	}
+	return bytes.Clone(m.raw)
+}
+
+// Source reports where m was read from. It is empty for documents passed to Parse.
+func (m *MetaData) Source() Source {
+	if m == nil {
+		return ""
+	}
+	return m.source
+}
+
+// Lookup returns the GitHub subsystems whose CIDR ranges contain the provided IP address.
+func (m *MetaData) Lookup(addr netip.Addr) []string {
+	if m == nil || !addr.IsValid() || len(m.entries) == 0 {
+		return nil
+	}
+	if m.cache == nil {
+		return m.lookup(addr)
+	}
+	if labels, ok := m.cache.get(addr); ok {
+		return labels
+	}
+	labels := m.lookup(addr)
+	m.cache.add(addr, labels)
+	return labels
+}
+
+// lookup is Lookup without the cache.
+func (m *MetaData) lookup(addr netip.Addr) []string {
+	labels := make([]string, 0, 2)
+	m.familyIndex(addr).containing(addr, func(entry Entry) {
+		if !slices.Contains(labels, entry.Label) {
+			labels = append(labels, entry.Label)
+		}
+	})
+
+	sort.Strings(labels)
+	return labels
+}
+
+// LookupEntries returns every entry whose prefix contains the provided IP address,
+// ordered by label and then prefix.
+func (m *MetaData) LookupEntries(addr netip.Addr) []Entry {
+	if m == nil || !addr.IsValid() {
+		return nil
+	}
+
+	var matches []Entry
+	m.familyIndex(addr).containing(addr, func(entry Entry) {
+		matches = append(matches, entry)
+	})
+	sortEntries(matches)
+	return matches
+}
+
+// familyIndex returns the prefixIndex of addr's address family.
+func (m *MetaData) familyIndex(addr netip.Addr) prefixIndex {
+	if addr.Is4() {
+		return m.index4
+	}
+	return m.index6
+}
+
+// familyEntries returns the entries sharing addr's address family.
+func (m *MetaData) familyEntries(addr netip.Addr) []Entry {
+	if addr.Is4() {
+		return m.entries4
+	}
+	return m.entries6
+}
+
+// FetchWithTimeout is a convenience helper that applies a timeout to the fetch operation.
+func FetchWithTimeout(timeout time.Duration) (*MetaData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return Fetch(ctx, nil)
+}
+
+// fetchConfig holds the per-call settings of fetch.
+type fetchConfig struct {
+	client *http.Client
+	// endpoint is the URL requested; empty means metaEndpoint.
+	endpoint string
+	// pin, when set, is the SHA-256 a fresh payload must have.
+	pin string
+	// maxBytes caps the size of a response body.
+	maxBytes int64
+	// strict rejects responses without entries; see FetchOptions.StrictEntries.
+	strict bool
+	// requestFunc decorates each request; see FetchOptions.RequestFunc.
+	requestFunc func(*http.Request)
+	// pinResolvedIP retries DNS failures against the last known address; see
+	// FetchOptions.PinResolvedIP.
+	pinResolvedIP bool
+	// families are the address families kept; see FetchOptions.Families.
+	families Families
+	// warnf reports non-fatal problems such as an unrecognized schema version.
+	warnf func(format string, args ...any)
+	// tracef reports cache decisions; see FetchOptions.Tracef.
+	tracef func(format string, args ...any)
+	// cacheTTL, when positive, serves a cache younger than it without a request; see
+	// FetchOptions.PreferCache.
+	cacheTTL time.Duration
+}
+
+func (cfg fetchConfig) trace(format string, args ...any) {
+	if cfg.tracef != nil {
+		cfg.tracef(format, args...)
+	}
+}
+
+// fallback serves store in place of a request that failed with reqErr, tracing
+// whether it could.
+func (cfg fetchConfig) fallback(store *cacheStore, reqErr error) (*MetaData, error) {
+	meta, err := store.fallback(reqErr)
+	if err != nil {
+		cfg.trace("%v → no usable cache to fall back on", reqErr)
+	} else {
+		cfg.trace("%v → fell back to the cached copy", reqErr)
+	}
+	return meta, err
+}
+
+// fetch requests the meta data, revalidating and falling back to store.
+func fetch(ctx context.Context, cfg fetchConfig, store *cacheStore) (*MetaData, error) {
+	if cfg.client == nil {
+		cfg.client = defaultClient
+	}
+	if cfg.endpoint == "" {
+		cfg.endpoint = metaEndpoint
+	}
+
+	// An expired or cancelled context would only surface as an opaque transport
+	// error after the cache fallback below, so report it up front.
+	if err := ctx.Err(); err != nil {
+		return cfg.fallback(store, fmt.Errorf("fetch github meta: context done before request: %w", err))
+	}
+
+	if store == nil {
+		cfg.trace("caching disabled")
+	} else {
+		cfg.trace("cache directory %s (read-only: %t)", store.dir, store.readOnly)
+	}
+	if cfg.cacheTTL > 0 {
+		if age, ok := store.age(); ok && age < cfg.cacheTTL {
+			meta, err := store.load()
+			if err == nil {
+				cfg.trace("cache is %s old, within the %s TTL → served without a request", age.Round(time.Second), cfg.cacheTTL)
+				return meta, nil
+			}
+			cfg.trace("cache is fresh but unreadable (%v) → requesting", err)
+		} else if ok {
+			cfg.trace("cache is %s old, past the %s TTL → requesting", age.Round(time.Second), cfg.cacheTTL)
+		}
+	}
+	etag := store.readETag()
+	if etag != "" {
+		cfg.trace("sending If-None-Match %s", etag)
+	} else {
+		cfg.trace("no cached ETag; requesting the full document")
+	}
+	meta, err := fetchOnce(ctx, cfg, store, etag)
+	if errors.Is(err, errNotModifiedWithoutCache) {
+		cfg.trace("%v → retrying without the ETag", err)
+		// The ETag outlived its payload (e.g. meta.json was deleted by hand). Drop it
+		// and ask for the full document so the cache heals instead of wedging.
+		store.clearETag()
+		return fetchOnce(ctx, cfg, store, "")
+	}
+	if errors.Is(err, ErrSecondaryRateLimited) {
+		// The secondary limit carries no Retry-After, so back off for a fixed,
+		// generous interval and try exactly once more. A wait that would outlast the
+		// deadline is pointless; failing now leaves time for the fallbacks.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < secondaryRateLimitBackoff {
+			cfg.trace("%v → the %s backoff would outlast the deadline; not retrying", err, secondaryRateLimitBackoff)
+			return meta, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: gave up waiting to retry: %v", ErrSecondaryRateLimited, ctx.Err())
+		case <-time.After(secondaryRateLimitBackoff):
+		}
+		return fetchOnce(ctx, cfg, store, etag)
+	}
+	return meta, err
+}
+
+// isSecondaryRateLimit reports whether resp is GitHub's abuse-detection rejection:
+// a 403 whose body mentions the secondary rate limit. Only a bounded prefix of the
+// body is read.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit"))
+}
+
+// isJSONContentType reports whether ct, a Content-Type header value, names JSON:
+// application/json or a +json type, with any parameters such as charset. A missing
+// header is given the benefit of the doubt and left to the parser.
+func isJSONContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// newRequest builds a request to the meta endpoint with the default headers,
+// sending etag as If-None-Match when non-empty, and applies cfg.requestFunc.
+func (cfg fetchConfig) newRequest(ctx context.Context, method, etag string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, cfg.endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if cfg.requestFunc != nil {
+		cfg.requestFunc(req)
+		if req.Context() != ctx {
+			req = req.WithContext(ctx)
+		}
+	}
+	return req, nil
+}
+
+// errNotModifiedWithoutCache reports a 304 response that cannot be served because
+// the cached payload is missing or unreadable.
+var errNotModifiedWithoutCache = errors.New("meta endpoint returned 304 but the cached meta data is unavailable")
+
+// fetchOnce performs a single request, sending etag as If-None-Match when non-empty.
+func fetchOnce(ctx context.Context, cfg fetchConfig, store *cacheStore, etag string) (*MetaData, error) {
+	req, err := cfg.newRequest(ctx, http.MethodGet, etag)
+	if err != nil {
+		return nil, err
+	}
+	host := req.URL.Hostname()
+	resolved := func() netip.Addr { return netip.Addr{} }
+	if cfg.pinResolvedIP {
+		ctx, resolved = withResolvedAddrTrace(ctx, host)
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil && cfg.pinResolvedIP && isDNSFailure(err, host) {
+		if addr := store.readResolvedAddr(host); addr.IsValid() {
+			if client, ok := pinnedClient(cfg.client, addr); ok {
+				cfg.trace("resolving %s failed → retrying against its last known address %s", host, addr)
+				resp, err = client.Do(req.Clone(ctx))
+			}
+		}
+	}
+	if err != nil {
+		return cfg.fallback(store, fmt.Errorf("fetch github meta: %w", err))
+	}
+	defer resp.Body.Close()
+	if addr := resolved(); addr.IsValid() {
+		if err := store.saveResolvedAddr(host, addr); err != nil {
+			store.warnf("could not record the address of %s: %v", host, err)
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		meta, err := store.load()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errNotModifiedWithoutCache, err)
+		}
+		// The copy is now known to be current, which is what CacheTTL measures from.
+		store.touch()
+		cfg.trace("304 → served from cache")
+		return meta, nil
+	case http.StatusOK:
+		if ct := resp.Header.Get("Content-Type"); !isJSONContentType(ct) {
+			return cfg.fallback(store, fmt.Errorf("%w: got Content-Type %q; a proxy or captive portal may be intercepting requests", ErrNonJSONResponse, ct))
+		}
+		// Read one byte past the cap so an oversized body is detected rather than truncated.
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, cfg.maxBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("read meta response: %w", err)
+		}
+		if int64(len(raw)) > cfg.maxBytes {
+			return nil, fmt.Errorf("%w: body exceeds %d bytes", ErrResponseTooLarge, cfg.maxBytes)
+		}
+		if sum := payloadSHA256(raw); cfg.pin != "" && sum != cfg.pin {
+			return nil, fmt.Errorf("%w: meta response has SHA-256 %s, expected %s", ErrChecksumMismatch, sum, cfg.pin)
+		}
+		meta, err := parse(bytes.NewReader(raw), cfg.families)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.strict && len(meta.entries) == 0 {
+			return nil, ErrNoEntries
+		}
+		if n := len(meta.duplicates); n > 0 && cfg.warnf != nil {
+			cfg.warnf("meta response lists %d prefixes more than once under the same label; the repeats were ignored", n)
+		}
+		meta.etag = resp.Header.Get("ETag")
+		meta.source = SourceNetwork
+		if v := resp.Header.Get(SchemaVersionHeader); v != "" {
+			meta.schemaVersion = v
+		}
+		if !knownSchemaVersions[meta.schemaVersion] && cfg.warnf != nil {
+			cfg.warnf("meta schema version %q is not recognized; lookups may miss ranges the parser does not understand", meta.schemaVersion)
+		}
+		switch err := store.save(raw, meta.etag); {
+		case err != nil:
+			// caching failures are non-fatal
+			store.warnf("could not update cache: %v", err)
+			cfg.trace("200 → served from the network; saving to the cache failed: %v", err)
+		case store == nil:
+			cfg.trace("200 → served from the network; not cached")
+		case store.readOnly:
+			cfg.trace("200 → served from the network; cache is read-only, not saved")
+		default:
+			cfg.trace("200 → served from the network and saved to the cache (ETag %q)", meta.etag)
+		}
+		return meta, nil
+	default:
+		if isSecondaryRateLimit(resp) {
+			return cfg.fallback(store, ErrSecondaryRateLimited)
+		}
+		return cfg.fallback(store, fmt.Errorf("unexpected status %d from meta endpoint", resp.StatusCode))
+	}
+}
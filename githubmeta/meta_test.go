@@ -0,0 +1,770 @@
+package githubmeta
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleMeta = `{
+  "hooks": ["192.30.252.0/22", "2001:db8:1::/48"],
+  "web": ["140.82.112.0/20"],
+	"ssh_keys": ["ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"],
+  "verifiable_password_authentication": true,
+  "ssh_key_fingerprints": {"SHA256": "example"}
+}`
+
+func TestParseMetaJSON(t *testing.T) {
+	entries, err := parseMetaJSON(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+}
+
+func TestLookup(t *testing.T) {
+	entries, err := parseMetaJSON(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+	meta := newMetaData(entries)
+
+	addr := netip.MustParseAddr("192.30.252.42")
+	labels := meta.Lookup(addr)
+	if len(labels) != 1 || labels[0] != "hooks" {
+		t.Fatalf("expected [hooks], got %v", labels)
+	}
+
+	ipv6 := netip.MustParseAddr("2001:db8:1::213")
+	labels = meta.Lookup(ipv6)
+	if len(labels) != 1 || labels[0] != "hooks" {
+		t.Fatalf("expected [hooks], got %v", labels)
+	}
+
+	unknown := netip.MustParseAddr("8.8.8.8")
+	labels = meta.Lookup(unknown)
+	if len(labels) != 0 {
+		t.Fatalf("expected empty result, got %v", labels)
+	}
+}
+
+func TestFetchWithCacheDir_UsesCacheOn304(t *testing.T) {
+	tmpDir := t.TempDir()
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		calls++
+		if calls == 1 {
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(sampleMeta))
+			return
+		}
+		if got := r.Header.Get("If-None-Match"); got != `"v1"` {
+			t.Fatalf("expected If-None-Match header to be \"v1\", got %q", got)
+		}
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	ctx := context.Background()
+	client := srv.Client()
+
+	first, err := FetchWithCacheDir(ctx, client, tmpDir)
+	if err != nil {
+		t.Fatalf("first fetch failed: %v", err)
+	}
+	if len(first.Entries()) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(first.Entries()))
+	}
+
+	second, err := FetchWithCacheDir(ctx, client, tmpDir)
+	if err != nil {
+		t.Fatalf("second fetch failed: %v", err)
+	}
+	if len(second.Entries()) != len(first.Entries()) {
+		t.Fatalf("expected cached entries, got %d", len(second.Entries()))
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 HTTP calls, got %d", calls)
+	}
+	if first.Source() != SourceNetwork || second.Source() != SourceCache {
+		t.Fatalf("sources = %q, %q; want network, cache", first.Source(), second.Source())
+	}
+	for _, meta := range []*MetaData{first, second} {
+		if got := meta.RawJSON(); string(got) != strings.TrimSpace(sampleMeta) {
+			t.Fatalf("%s RawJSON = %q, want the served document", meta.Source(), got)
+		}
+	}
+	if raw := first.FilterLabels("hooks").RawJSON(); raw != nil {
+		t.Fatalf("a filtered MetaData should have no raw document, got %q", raw)
+	}
+}
+
+func TestFetchWithCacheDir_FallsBackToCacheOnError(t *testing.T) {
+	tmpDir := t.TempDir()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	ctx := context.Background()
+	client := srv.Client()
+
+	if _, err := FetchWithCacheDir(ctx, client, tmpDir); err != nil {
+		srv.Close()
+		t.Fatalf("initial fetch failed: %v", err)
+	}
+
+	srv.Close()
+
+	meta, err := FetchWithCacheDir(ctx, client, tmpDir)
+	if err != nil {
+		t.Fatalf("expected cached meta after error, got %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 cached entries, got %d", len(meta.Entries()))
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta-snapshot.json")
+	if err := os.WriteFile(path, []byte(sampleMeta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile returned error: %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(meta.Entries()))
+	}
+
+	if _, err := LoadFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("expected error for missing snapshot")
+	}
+}
+
+// linearLookup is the reference implementation Lookup must agree with.
+func linearLookup(entries []Entry, addr netip.Addr) []string {
+	seen := make(map[string]struct{})
+	var labels []string
+	for _, entry := range entries {
+		if entry.Prefix.Contains(addr) {
+			if _, ok := seen[entry.Label]; !ok {
+				seen[entry.Label] = struct{}{}
+				labels = append(labels, entry.Label)
+			}
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func TestLookupMatchesLinearScanAcrossFamilies(t *testing.T) {
+	entries, err := parseMetaJSON(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+	meta := newMetaData(entries)
+
+	for _, raw := range []string{
+		"192.30.252.0", "192.30.255.255", "140.82.127.1", "8.8.8.8",
+		"2001:db8:1::", "2001:db8:1:ffff::1", "2001:db8:2::1", "::ffff:192.30.252.1",
+	} {
+		addr := netip.MustParseAddr(raw)
+		got := meta.Lookup(addr)
+		want := linearLookup(entries, addr)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("Lookup(%s) = %v, linear scan = %v", raw, got, want)
+		}
+	}
+
+	if len(meta.Entries()) != len(entries) {
+		t.Fatalf("Entries() should return the combined view, got %d of %d", len(meta.Entries()), len(entries))
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	entries, err := parseMetaJSON(strings.NewReader(sampleMeta))
+	if err != nil {
+		b.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+	meta := newMetaData(entries)
+	v4 := netip.MustParseAddr("8.8.8.8")
+	v6 := netip.MustParseAddr("2001:db8:2::1")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		meta.Lookup(v4)
+		meta.Lookup(v6)
+	}
+}
+
+func TestLookupEntries(t *testing.T) {
+	entries, err := parseMetaJSON(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+	meta := newMetaData(entries)
+
+	matches := meta.LookupEntries(netip.MustParseAddr("2001:db8:1::213"))
+	if len(matches) != 1 || matches[0].Label != "hooks" || matches[0].Prefix.String() != "2001:db8:1::/48" {
+		t.Fatalf("expected hooks 2001:db8:1::/48, got %v", matches)
+	}
+	if matches := meta.LookupEntries(netip.MustParseAddr("8.8.8.8")); len(matches) != 0 {
+		t.Fatalf("expected no matches, got %v", matches)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	entries, err := parseMetaJSON(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+	meta := newMetaData(entries)
+
+	if got := strings.Join(meta.Labels(), ","); got != "hooks,web" {
+		t.Fatalf("expected hooks,web, got %s", got)
+	}
+	if labels := (*MetaData)(nil).Labels(); labels != nil {
+		t.Fatalf("expected nil labels for nil meta, got %v", labels)
+	}
+}
+
+func TestCompleteLabel(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	if got := meta.CompleteLabel("h"); !reflect.DeepEqual(got, []string{"hooks"}) {
+		t.Fatalf(`CompleteLabel("h") = %v, want [hooks]`, got)
+	}
+	if got := meta.CompleteLabel(""); !reflect.DeepEqual(got, meta.Labels()) {
+		t.Fatalf(`CompleteLabel("") = %v, want every label %v`, got, meta.Labels())
+	}
+	if got := meta.CompleteLabel("x"); got != nil {
+		t.Fatalf(`CompleteLabel("x") = %v, want none`, got)
+	}
+}
+
+func TestFetchWithCacheDir_DoneContextWithoutCache(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		calls++
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := FetchWithCacheDir(ctx, srv.Client(), t.TempDir())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no HTTP calls, got %d", calls)
+	}
+}
+
+func TestFetchWithCacheDir_DoneContextServesCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := newCacheStore(tmpDir).save([]byte(sampleMeta), `"v1"`); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	meta, err := FetchWithCacheDir(ctx, nil, tmpDir)
+	if err != nil {
+		t.Fatalf("expected cached meta, got %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 cached entries, got %d", len(meta.Entries()))
+	}
+}
+
+func TestFetchWithCacheDir_RecoversFrom304WithoutCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	var conditional, unconditional int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		unconditional++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	ctx := context.Background()
+	if _, err := FetchWithCacheDir(ctx, srv.Client(), tmpDir); err != nil {
+		t.Fatalf("initial fetch failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "meta.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := FetchWithCacheDir(ctx, srv.Client(), tmpDir)
+	if err != nil {
+		t.Fatalf("expected recovery after 304 without cache, got %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(meta.Entries()))
+	}
+	if conditional != 1 || unconditional != 2 {
+		t.Fatalf("expected one 304 followed by a full retry, got %d conditional and %d unconditional requests", conditional, unconditional)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "meta.json")); err != nil {
+		t.Fatalf("expected meta.json to be re-saved: %v", err)
+	}
+}
+
+func TestFetchWithCacheDir_RetriesAfterSecondaryRateLimit(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`))
+			return
+		}
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint, oldBackoff := metaEndpoint, secondaryRateLimitBackoff
+	metaEndpoint, secondaryRateLimitBackoff = srv.URL, time.Millisecond
+	defer func() {
+		metaEndpoint, secondaryRateLimitBackoff = oldEndpoint, oldBackoff
+	}()
+
+	meta, err := FetchWithCacheDir(context.Background(), srv.Client(), "")
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if len(meta.Entries()) != 3 || calls != 2 {
+		t.Fatalf("expected 3 entries after 2 calls, got %d entries after %d calls", len(meta.Entries()), calls)
+	}
+}
+
+func TestFetchWithCacheDir_SecondaryRateLimitPersists(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+	}))
+	defer srv.Close()
+
+	oldEndpoint, oldBackoff := metaEndpoint, secondaryRateLimitBackoff
+	metaEndpoint, secondaryRateLimitBackoff = srv.URL, time.Millisecond
+	defer func() {
+		metaEndpoint, secondaryRateLimitBackoff = oldEndpoint, oldBackoff
+	}()
+
+	_, err := FetchWithCacheDir(context.Background(), srv.Client(), "")
+	if !errors.Is(err, ErrSecondaryRateLimited) {
+		t.Fatalf("expected ErrSecondaryRateLimited, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected exactly one retry, got %d calls", calls)
+	}
+}
+
+func TestFetchWithCacheDir_SecondaryRateLimitBackoffPastDeadline(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+	}))
+	defer srv.Close()
+
+	oldEndpoint, oldBackoff := metaEndpoint, secondaryRateLimitBackoff
+	metaEndpoint, secondaryRateLimitBackoff = srv.URL, time.Minute
+	defer func() {
+		metaEndpoint, secondaryRateLimitBackoff = oldEndpoint, oldBackoff
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := FetchWithCacheDir(ctx, srv.Client(), "")
+	if !errors.Is(err, ErrSecondaryRateLimited) {
+		t.Fatalf("expected ErrSecondaryRateLimited, got %v", err)
+	}
+	if calls != 1 || ctx.Err() != nil {
+		t.Fatalf("expected an immediate failure without a retry, got %d calls after %s", calls, time.Since(start))
+	}
+}
+
+func TestMarshalJSONRoundTripsUnparsedFields(t *testing.T) {
+	meta, err := Parse(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	var keys []string
+	if err := json.Unmarshal(doc["ssh_keys"], &keys); err != nil || len(keys) != 1 || !strings.HasPrefix(keys[0], "ssh-ed25519 ") {
+		t.Fatalf("ssh_keys did not survive: %s", doc["ssh_keys"])
+	}
+	if string(doc["verifiable_password_authentication"]) != "true" || string(doc["ssh_key_fingerprints"]) != `{"SHA256":"example"}` {
+		t.Fatalf("scalar or object fields did not survive: %s", data)
+	}
+
+	again, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Entries(), meta.Entries()) {
+		t.Fatalf("entries changed after a round trip: %v, want %v", again.Entries(), meta.Entries())
+	}
+}
+
+func TestFilterLabelsKeepsUnparsedFields(t *testing.T) {
+	meta, err := Parse(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(meta.FilterLabels("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	if _, ok := doc["hooks"]; ok {
+		t.Fatalf("filtered document still has hooks: %s", data)
+	}
+	if string(doc["web"]) != `["140.82.112.0/20"]` || doc["ssh_keys"] == nil {
+		t.Fatalf("unexpected filtered document %s", data)
+	}
+}
+
+func TestFilterLabelsRunnerLabels(t *testing.T) {
+	meta := loadFixture(t, `{
+  "hooks": ["192.30.252.0/22"],
+  "actions": ["4.148.0.0/16"],
+  "actions_macos": ["13.105.49.0/24"],
+  "codespaces": ["20.42.11.16/28"]
+}`)
+	runners := meta.FilterLabels(RunnerLabels()...)
+
+	cases := map[string][]string{
+		"4.148.1.1":     {"actions"},
+		"13.105.49.7":   {"actions_macos"},
+		"20.42.11.17":   {"codespaces"},
+		"192.30.252.44": {},
+	}
+	for raw, want := range cases {
+		if got := runners.Lookup(netip.MustParseAddr(raw)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Lookup(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestFilterPrefixLen(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta).FilterPrefixLen(24, 0)
+
+	if got := meta.Lookup(netip.MustParseAddr("192.30.252.1")); !reflect.DeepEqual(got, []string{"api"}) {
+		t.Fatalf("Lookup(192.30.252.1) = %v, want [api]", got)
+	}
+	if got := meta.Lookup(netip.MustParseAddr("192.30.254.1")); len(got) != 0 {
+		t.Fatalf("the /22 hooks entry still matches: %v", got)
+	}
+	// The IPv6 /48 is within the bounds too: lengths are compared per family.
+	if got := meta.LabelPrefixes("hooks"); !reflect.DeepEqual(got, mustPrefixes("2001:db8:1::/48")) {
+		t.Fatalf("hooks prefixes = %v", got)
+	}
+
+	narrow := loadFixture(t, fixtureMeta).FilterPrefixLen(0, 22)
+	if got := narrow.LabelPrefixes("api"); len(got) != 0 {
+		t.Fatalf("max=22 kept the /24 api entry: %v", got)
+	}
+	if got := len(narrow.Entries()); got != 3 {
+		t.Fatalf("max=22 kept %d entries, want 3", got)
+	}
+}
+
+func TestFetchRejectsOversizedResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Stream a valid-looking document that never fits under the cap.
+		_, _ = w.Write([]byte(`{"hooks": ["192.30.252.0/22"], "padding": "`))
+		chunk := bytes.Repeat([]byte("x"), 1024)
+		for i := 0; i < 64; i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+		_, _ = w.Write([]byte(`"}`))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	_, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), MaxResponseBytes: 16 << 10})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "meta response too large") {
+		t.Fatalf("unexpected error text %q", err)
+	}
+
+	if _, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client()}); err != nil {
+		t.Fatalf("default cap rejected a small response: %v", err)
+	}
+}
+
+func TestPrefixCount(t *testing.T) {
+	meta, err := Parse(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, v4, v6 := meta.PrefixCount(); total != 3 || v4 != 2 || v6 != 1 {
+		t.Fatalf("PrefixCount() = %d, %d, %d, want 3, 2, 1", total, v4, v6)
+	}
+	var nilMeta *MetaData
+	if total, _, _ := nilMeta.PrefixCount(); total != 0 {
+		t.Fatalf("nil PrefixCount() total = %d", total)
+	}
+}
+
+func TestParseRejectsNonObjectDocuments(t *testing.T) {
+	for doc, kind := range map[string]string{
+		`["192.30.252.0/22"]`: "array",
+		`"not found"`:         "string",
+		`null`:                "null",
+	} {
+		_, err := Parse(strings.NewReader(doc))
+		if !errors.Is(err, ErrUnexpectedMetaShape) {
+			t.Fatalf("Parse(%s) error = %v, want ErrUnexpectedMetaShape", doc, err)
+		}
+		if !strings.Contains(err.Error(), "got a JSON "+kind) {
+			t.Fatalf("Parse(%s) error %q does not name the %s", doc, err, kind)
+		}
+	}
+}
+
+func TestFetchCapturesSchemaVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(SchemaVersionHeader, "2")
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	var warnings []string
+	meta, err := FetchWithOptions(context.Background(), FetchOptions{
+		Client: srv.Client(),
+		Warnf:  func(format string, args ...any) { warnings = append(warnings, fmt.Sprintf(format, args...)) },
+	})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if got := meta.SchemaVersion(); got != "2" {
+		t.Fatalf("SchemaVersion() = %q, want %q", got, "2")
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `schema version "2" is not recognized`) {
+		t.Fatalf("expected one unrecognized-version warning, got %q", warnings)
+	}
+}
+
+func TestParseReadsSchemaVersionField(t *testing.T) {
+	meta, err := Parse(strings.NewReader(`{"schema_version": "1", "hooks": ["192.30.252.0/22"]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := meta.SchemaVersion(); got != "1" {
+		t.Fatalf("SchemaVersion() = %q, want %q", got, "1")
+	}
+
+	meta, err = Parse(strings.NewReader(sampleMeta))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := meta.SchemaVersion(); got != "" {
+		t.Fatalf("unversioned document has SchemaVersion() = %q", got)
+	}
+}
+
+func TestFetchRejectsNonJSONResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte("<html><body>Sign in to continue</body></html>"))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	_, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client()})
+	if !errors.Is(err, ErrNonJSONResponse) {
+		t.Fatalf("expected ErrNonJSONResponse, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"text/html; charset=utf-8"`) {
+		t.Fatalf("error %q does not name the received Content-Type", err)
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"":                                true,
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"Application/JSON;charset=UTF-8":  true,
+		"application/vnd.github+json":     true,
+		"text/html; charset=utf-8":        false,
+		"text/plain":                      false,
+		"not a media type;;":              false,
+	} {
+		if got := isJSONContentType(ct); got != want {
+			t.Errorf("isJSONContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+func TestParseAcceptsDocumentWithoutCIDRs(t *testing.T) {
+	const sshOnly = `{"ssh_keys": ["ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"], "verifiable_password_authentication": false}`
+
+	meta, err := Parse(strings.NewReader(sshOnly))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if n := len(meta.Entries()); n != 0 {
+		t.Fatalf("expected no entries, got %d", n)
+	}
+	if labels := meta.Lookup(netip.MustParseAddr("192.30.252.1")); labels != nil {
+		t.Fatalf("Lookup on empty meta = %v, want nil", labels)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil || !strings.Contains(string(data), `"ssh_keys"`) {
+		t.Fatalf("MarshalJSON lost the extra fields: %s, %v", data, err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(sshOnly))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	if _, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client()}); err != nil {
+		t.Fatalf("non-strict fetch of an empty document failed: %v", err)
+	}
+	if _, err := FetchWithOptions(context.Background(), FetchOptions{Client: srv.Client(), StrictEntries: true}); !errors.Is(err, ErrNoEntries) {
+		t.Fatalf("strict fetch error = %v, want ErrNoEntries", err)
+	}
+}
+
+func TestFetchRequestFuncDecoratesRequest(t *testing.T) {
+	var gotTrace, gotAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTrace, gotAgent = r.Header.Get("X-Trace-Id"), r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := FetchWithOptions(context.Background(), FetchOptions{
+		Client: srv.Client(),
+		RequestFunc: func(req *http.Request) {
+			req.Header.Set("X-Trace-Id", "abc123")
+			// Swapping in another context must not take effect.
+			*req = *req.WithContext(ctx)
+		},
+	})
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if gotTrace != "abc123" {
+		t.Fatalf("server saw X-Trace-Id %q, want abc123", gotTrace)
+	}
+	if gotAgent != "cidr-calculator-github/1.0" {
+		t.Fatalf("default User-Agent was lost: %q", gotAgent)
+	}
+}
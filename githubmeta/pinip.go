@@ -0,0 +1,116 @@
+package githubmeta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// withResolvedAddrTrace returns ctx carrying a trace that watches for a successful
+// connection made after resolving host, and a func returning that connection's
+// address (the zero Addr if there was none). Connections to a proxy resolve the
+// proxy's name instead, so they are not recorded. Dials may outlive the request,
+// so the address is guarded by a mutex.
+func withResolvedAddrTrace(ctx context.Context, host string) (context.Context, func() netip.Addr) {
+	var mu sync.Mutex
+	resolved := false
+	var last netip.Addr
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			resolved = info.Host == host
+		},
+		ConnectDone: func(_, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || !resolved {
+				return
+			}
+			if ap, err := netip.ParseAddrPort(addr); err == nil {
+				last = ap.Addr().Unmap()
+			}
+		},
+	})
+	return ctx, func() netip.Addr {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+// isDNSFailure reports whether err is a failure to resolve host.
+func isDNSFailure(err error, host string) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.Name == host
+}
+
+// pinnedClient returns a copy of client that connects to addr whatever host a
+// request names. The URL is unchanged, so the Host header and TLS server name still
+// carry the endpoint's name. It fails for transports other than *http.Transport.
+func pinnedClient(client *http.Client, addr netip.Addr) (*http.Client, bool) {
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	base, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, false
+	}
+	t := base.Clone()
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, hostport string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, net.JoinHostPort(addr.String(), port))
+	}
+	pinned := *client
+	pinned.Transport = t
+	return &pinned, true
+}
+
+// resolvedPath holds the endpoint host and the address it last resolved to, for
+// FetchOptions.PinResolvedIP.
+func (c *cacheStore) resolvedPath() string {
+	return filepath.Join(c.dir, "meta.resolved")
+}
+
+// readResolvedAddr returns the address recorded for host, or the zero Addr.
+func (c *cacheStore) readResolvedAddr(host string) netip.Addr {
+	if c == nil {
+		return netip.Addr{}
+	}
+	data, err := os.ReadFile(c.resolvedPath())
+	if err != nil {
+		return netip.Addr{}
+	}
+	fields := bytes.Fields(data)
+	if len(fields) != 2 || string(fields[0]) != host {
+		return netip.Addr{}
+	}
+	addr, _ := netip.ParseAddr(string(fields[1]))
+	return addr
+}
+
+// saveResolvedAddr records that host last resolved to addr.
+func (c *cacheStore) saveResolvedAddr(host string, addr netip.Addr) error {
+	if c == nil || c.readOnly {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.resolvedPath(), []byte(host+" "+addr.String()+"\n"), 0o644)
+}
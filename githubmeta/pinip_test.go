@@ -0,0 +1,62 @@
+package githubmeta
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestFetchPinResolvedIPRetriesDNSFailure(t *testing.T) {
+	var hosts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A transport without a proxy and a resolver that cannot resolve anything but
+	// localhost, standing in for a DNS outage.
+	resolver := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: net.UnknownNetworkError("dns outage")}
+	}}
+	client := &http.Client{Transport: &http.Transport{DialContext: (&net.Dialer{Resolver: resolver}).DialContext}}
+	dir := t.TempDir()
+	fetchFrom := func(host string) error {
+		_, err := FetchWithOptions(context.Background(), FetchOptions{
+			Client:        client,
+			CacheDir:      dir,
+			Endpoint:      "http://" + net.JoinHostPort(host, port) + "/meta",
+			PinResolvedIP: true,
+		})
+		return err
+	}
+
+	// A successful lookup is recorded for the host that was resolved.
+	if err := fetchFrom("localhost"); err != nil {
+		t.Skipf("localhost is not reachable here: %v", err)
+	}
+	store := newCacheStore(dir)
+	if got := store.readResolvedAddr("localhost"); !got.IsLoopback() {
+		t.Fatalf("expected a loopback address recorded for localhost, got %v", got)
+	}
+
+	// Resolving meta.invalid fails; the retry goes to its recorded address and still
+	// names the host.
+	if err := store.saveResolvedAddr("meta.invalid", netip.MustParseAddr("127.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchFrom("meta.invalid"); err != nil {
+		t.Fatalf("expected the pinned retry to succeed, got %v", err)
+	}
+	if want := net.JoinHostPort("meta.invalid", port); hosts[len(hosts)-1] != want {
+		t.Fatalf("retry sent Host %q, want %q", hosts[len(hosts)-1], want)
+	}
+}
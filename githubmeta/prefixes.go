@@ -0,0 +1,397 @@
+package githubmeta
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Coalesce returns the smallest set of prefixes covering exactly the addresses covered
+// by the input: prefixes are masked, nested prefixes are dropped, and sibling halves
+// are merged into their parent. The result is sorted with IPv4 before IPv6.
+func Coalesce(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		if p.IsValid() {
+			sorted = append(sorted, p.Masked())
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return comparePrefixes(sorted[i], sorted[j]) < 0
+	})
+
+	var out []netip.Prefix
+	for _, p := range sorted {
+		if len(out) > 0 && out[len(out)-1].Overlaps(p) {
+			// Sorted by address then length, so an overlap means p is nested.
+			continue
+		}
+		out = append(out, p)
+		for len(out) >= 2 {
+			parent, ok := mergeSiblings(out[len(out)-2], out[len(out)-1])
+			if !ok {
+				break
+			}
+			out = append(out[:len(out)-2], parent)
+		}
+	}
+	return out
+}
+
+// comparePrefixes orders prefixes by network address, then by shorter length first.
+func comparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// mergeSiblings returns the parent of a and b when they are the two halves of it.
+func mergeSiblings(a, b netip.Prefix) (netip.Prefix, bool) {
+	if a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().Is4() != b.Addr().Is4() {
+		return netip.Prefix{}, false
+	}
+	parent := netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked()
+	if parent.Addr() != a.Addr() || a == b || !parent.Contains(b.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return parent, true
+}
+
+// MinimalCoveringPrefix returns the smallest single prefix containing every address
+// of addrs, such as 192.30.252.0/22 for a handful of addresses seen in logs. The
+// addresses must all be of the same family; an IPv4-mapped IPv6 address counts as
+// IPv6. Zones are ignored.
+func MinimalCoveringPrefix(addrs []netip.Addr) (netip.Prefix, error) {
+	if len(addrs) == 0 {
+		return netip.Prefix{}, errors.New("no addresses to cover")
+	}
+	lo, hi := addrs[0].WithZone(""), addrs[0].WithZone("")
+	for _, addr := range addrs {
+		if !addr.IsValid() {
+			return netip.Prefix{}, errors.New("invalid address")
+		}
+		addr = addr.WithZone("")
+		if addr.BitLen() != lo.BitLen() {
+			return netip.Prefix{}, fmt.Errorf("cannot cover both %s and %s: mixed address families", lo, addr)
+		}
+		if addr.Less(lo) {
+			lo = addr
+		}
+		if hi.Less(addr) {
+			hi = addr
+		}
+	}
+	// The longest prefix of lo that also contains hi contains everything between.
+	for bits := lo.BitLen(); ; bits-- {
+		if p, _ := lo.Prefix(bits); p.Contains(hi) {
+			return p, nil
+		}
+	}
+}
+
+// PrefixSize returns the number of addresses in p.
+func PrefixSize(p netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+}
+
+// LabelPrefixes returns the coalesced prefixes published under label, matched without
+// regard to case, or nil for an unknown label.
+func (m *MetaData) LabelPrefixes(label string) []netip.Prefix {
+	if m == nil {
+		return nil
+	}
+	var prefixes []netip.Prefix
+	for _, entry := range m.entries {
+		if strings.EqualFold(entry.Label, label) {
+			prefixes = append(prefixes, entry.Prefix)
+		}
+	}
+	return Coalesce(prefixes)
+}
+
+// EntriesOverlapping returns every entry whose prefix overlaps p, i.e. contains it
+// or lies inside it, in the label/prefix order of Entries. No addresses are iterated,
+// so p may be arbitrarily large.
+func (m *MetaData) EntriesOverlapping(p netip.Prefix) []Entry {
+	if m == nil || !p.IsValid() {
+		return nil
+	}
+	var matches []Entry
+	for _, entry := range m.familyEntries(p.Addr()) {
+		if entry.Prefix.Overlaps(p) {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+// Relation says how a prefix relates to an entry it overlaps. Two prefixes that
+// overlap always nest, so one of the three holds.
+type Relation string
+
+const (
+	// RelationEqual is the entry's own prefix.
+	RelationEqual Relation = "equal"
+	// RelationSubset lies inside the entry's prefix.
+	RelationSubset Relation = "subset_of"
+	// RelationSuperset contains the entry's prefix.
+	RelationSuperset Relation = "superset_of"
+)
+
+// PrefixRelation pairs an overlapping entry with the Relation of a prefix to it.
+type PrefixRelation struct {
+	Entry    Entry
+	Relation Relation
+}
+
+// Relations compares p with every entry it overlaps, in the order of
+// EntriesOverlapping, by prefix length alone; no addresses are iterated.
+func (m *MetaData) Relations(p netip.Prefix) []PrefixRelation {
+	p = p.Masked()
+	var relations []PrefixRelation
+	for _, entry := range m.EntriesOverlapping(p) {
+		rel := RelationEqual
+		switch bits := entry.Prefix.Bits(); {
+		case p.Bits() > bits:
+			rel = RelationSubset
+		case p.Bits() < bits:
+			rel = RelationSuperset
+		}
+		relations = append(relations, PrefixRelation{Entry: entry, Relation: rel})
+	}
+	return relations
+}
+
+// CoveredSupernets returns the distinct IPv4 /bits blocks that contain at least one
+// entry, sorted, for a coarse routing-level view of GitHub's space. An entry broader
+// than /bits is returned whole rather than split into /bits blocks. IPv6 entries are
+// left out, since a useful granularity differs per family; see CoveredSupernets6.
+// It returns nil when bits is not between 0 and 32.
+func (m *MetaData) CoveredSupernets(bits int) []netip.Prefix {
+	if m == nil || bits < 0 || bits > 32 {
+		return nil
+	}
+	return coveredSupernets(m.entries4, bits)
+}
+
+// CoveredSupernets6 is CoveredSupernets for IPv6 entries, with bits between 0 and 128.
+func (m *MetaData) CoveredSupernets6(bits int) []netip.Prefix {
+	if m == nil || bits < 0 || bits > 128 {
+		return nil
+	}
+	return coveredSupernets(m.entries6, bits)
+}
+
+func coveredSupernets(entries []Entry, bits int) []netip.Prefix {
+	blocks := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		blocks = append(blocks, netip.PrefixFrom(entry.Prefix.Addr(), min(bits, entry.Prefix.Bits())).Masked())
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return comparePrefixes(blocks[i], blocks[j]) < 0
+	})
+
+	var out []netip.Prefix
+	for _, p := range blocks {
+		if len(out) > 0 && out[len(out)-1].Overlaps(p) {
+			// A repeat of the previous block, or nested in a broader entry.
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// Perimeter returns the addresses just outside GitHub's space, for watching whether
+// the ranges grow: for every run of contiguous coalesced prefixes, the address
+// below its first address and the one above its last, sorted with IPv4 first.
+// Prefixes that touch form one run, so the owned addresses between them are not
+// reported, and a run starting or ending at the edge of the address space has no
+// neighbour on that side.
+func (m *MetaData) Perimeter() []netip.Addr {
+	if m == nil {
+		return nil
+	}
+	prefixes := make([]netip.Prefix, 0, len(m.entries))
+	for _, entry := range m.entries {
+		prefixes = append(prefixes, entry.Prefix)
+	}
+
+	var out []netip.Addr
+	var first, last netip.Addr
+	flush := func() {
+		if !first.IsValid() {
+			return
+		}
+		// Prev and Next return the zero Addr instead of wrapping around.
+		if below := first.Prev(); below.IsValid() {
+			out = append(out, below)
+		}
+		if above := last.Next(); above.IsValid() {
+			out = append(out, above)
+		}
+	}
+	for _, p := range Coalesce(prefixes) {
+		if first.IsValid() && last.Next() == FirstAddr(p) {
+			last = LastAddr(p)
+			continue
+		}
+		flush()
+		first, last = FirstAddr(p), LastAddr(p)
+	}
+	flush()
+	return out
+}
+
+// OwnedCount returns how many addresses of p are covered by at least one entry. It
+// works from the overlapping prefixes instead of visiting addresses, so unlike
+// EvaluatePrefix it has no size limit.
+func (m *MetaData) OwnedCount(p netip.Prefix) *big.Int {
+	total := new(big.Int)
+	if m == nil || !p.IsValid() {
+		return total
+	}
+	p = p.Masked()
+	var covered []netip.Prefix
+	for _, entry := range m.EntriesOverlapping(p) {
+		// Overlapping prefixes always nest, so the intersection is the longer one.
+		inner := entry.Prefix.Masked()
+		if p.Bits() > inner.Bits() {
+			inner = p
+		}
+		covered = append(covered, inner)
+	}
+	for _, q := range Coalesce(covered) {
+		total.Add(total, PrefixSize(q))
+	}
+	return total
+}
+
+// PrefixLengthHistogram counts the entries of each prefix length, separately for
+// IPv4 and IPv6. A prefix published under several labels is counted once per label,
+// as in Entries.
+func (m *MetaData) PrefixLengthHistogram() (v4, v6 map[int]int) {
+	v4, v6 = make(map[int]int), make(map[int]int)
+	if m == nil {
+		return v4, v6
+	}
+	for _, entry := range m.entries4 {
+		v4[entry.Prefix.Bits()]++
+	}
+	for _, entry := range m.entries6 {
+		v6[entry.Prefix.Bits()]++
+	}
+	return v4, v6
+}
+
+// LabelOverlap returns the number of addresses covered by both labels' prefixes.
+// Unknown labels contribute no addresses, so the result is zero.
+func (m *MetaData) LabelOverlap(a, b string) *big.Int {
+	total := new(big.Int)
+	prefixesB := m.LabelPrefixes(b)
+	for _, pa := range m.LabelPrefixes(a) {
+		for _, pb := range prefixesB {
+			if !pa.Overlaps(pb) {
+				continue
+			}
+			// Two overlapping prefixes always nest, so the intersection is the longer one.
+			inner := pa
+			if pb.Bits() > pa.Bits() {
+				inner = pb
+			}
+			total.Add(total, PrefixSize(inner))
+		}
+	}
+	return total
+}
+
+// LabelPrefixDiff partitions the addresses of labels a and b, matched without regard
+// to case, into those only a covers, those only b covers and those both cover. Each
+// result is coalesced and sorted like Coalesce. Unknown labels cover nothing, so the
+// other label's prefixes all land in its only-set.
+func (m *MetaData) LabelPrefixDiff(a, b string) (onlyA, onlyB, both []netip.Prefix) {
+	prefixesA, prefixesB := m.LabelPrefixes(a), m.LabelPrefixes(b)
+	for _, pa := range prefixesA {
+		for _, pb := range prefixesB {
+			if !pa.Overlaps(pb) {
+				continue
+			}
+			// Two overlapping prefixes always nest, so the intersection is the longer one.
+			inner := pa
+			if pb.Bits() > pa.Bits() {
+				inner = pb
+			}
+			both = append(both, inner)
+		}
+	}
+	both = Coalesce(both)
+	return Subtract(prefixesA, both), Subtract(prefixesB, both), both
+}
+
+// Subtract returns the smallest set of prefixes covering the addresses of from that
+// no prefix of remove covers, coalesced and sorted like Coalesce.
+func Subtract(from, remove []netip.Prefix) []netip.Prefix {
+	remaining := Coalesce(from)
+	for _, cover := range Coalesce(remove) {
+		var next []netip.Prefix
+		for _, piece := range remaining {
+			next = append(next, subtractPrefix(piece, cover)...)
+		}
+		remaining = next
+	}
+	return Coalesce(remaining)
+}
+
+// ComplementWithin returns the minimal prefixes inside p that are not covered by any
+// entry. It returns p itself (masked) when nothing overlaps and nil when p is fully covered.
+func (m *MetaData) ComplementWithin(p netip.Prefix) []netip.Prefix {
+	if !p.IsValid() {
+		return nil
+	}
+	remaining := []netip.Prefix{p.Masked()}
+	if m == nil {
+		return remaining
+	}
+
+	var covering []netip.Prefix
+	for _, entry := range m.familyEntries(p.Addr()) {
+		if entry.Prefix.Overlaps(p) {
+			covering = append(covering, entry.Prefix)
+		}
+	}
+	for _, cover := range Coalesce(covering) {
+		var next []netip.Prefix
+		for _, piece := range remaining {
+			next = append(next, subtractPrefix(piece, cover)...)
+		}
+		remaining = next
+	}
+
+	sort.Slice(remaining, func(i, j int) bool {
+		return comparePrefixes(remaining[i], remaining[j]) < 0
+	})
+	return remaining
+}
+
+// subtractPrefix returns the prefixes covering p minus cover, splitting p in half
+// until each piece is either disjoint from cover or inside it.
+func subtractPrefix(p, cover netip.Prefix) []netip.Prefix {
+	if !p.Overlaps(cover) {
+		return []netip.Prefix{p}
+	}
+	if cover.Bits() <= p.Bits() {
+		return nil
+	}
+	lower, upper := splitPrefix(p)
+	return append(subtractPrefix(lower, cover), subtractPrefix(upper, cover)...)
+}
+
+// splitPrefix returns the two halves of the masked prefix p, which must not be a single address.
+func splitPrefix(p netip.Prefix) (netip.Prefix, netip.Prefix) {
+	lower := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	return lower, netip.PrefixFrom(LastAddr(lower).Next(), p.Bits()+1)
+}
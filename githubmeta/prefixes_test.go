@@ -0,0 +1,305 @@
+package githubmeta
+
+import (
+	"maps"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// fixtureMeta nests api inside hooks and keeps web and pages disjoint from both.
+const fixtureMeta = `{
+  "hooks": ["192.30.252.0/22", "2001:db8:1::/48"],
+  "web": ["140.82.112.0/20"],
+  "api": ["192.30.252.0/24"],
+  "pages": ["185.199.108.0/22"]
+}`
+
+func loadFixture(t testing.TB, raw string) *MetaData {
+	t.Helper()
+	entries, err := parseMetaJSON(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parseMetaJSON returned error: %v", err)
+	}
+	return newMetaData(entries)
+}
+
+func mustPrefixes(raw ...string) []netip.Prefix {
+	out := make([]netip.Prefix, len(raw))
+	for i, r := range raw {
+		out[i] = netip.MustParsePrefix(r)
+	}
+	return out
+}
+
+func prefixesString(prefixes []netip.Prefix) string {
+	parts := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestCoalesce(t *testing.T) {
+	got := Coalesce(mustPrefixes(
+		"10.0.1.0/24", "10.0.0.0/24", "10.0.0.128/25", "10.0.2.5/24", "10.0.3.0/24",
+		"2001:db8::/33", "2001:db8:8000::/33",
+	))
+	want := "10.0.0.0/22 2001:db8::/32"
+	if prefixesString(got) != want {
+		t.Fatalf("Coalesce = %q, want %q", prefixesString(got), want)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	got := Subtract(
+		mustPrefixes("10.0.0.0/22", "10.1.0.0/24", "2001:db8::/32"),
+		mustPrefixes("10.0.1.0/24", "10.1.0.0/16", "2001:db8:8000::/33"),
+	)
+	want := "10.0.0.0/24 10.0.2.0/23 2001:db8::/33"
+	if prefixesString(got) != want {
+		t.Fatalf("Subtract = %q, want %q", prefixesString(got), want)
+	}
+	if got := Subtract(mustPrefixes("10.0.0.0/24"), nil); prefixesString(got) != "10.0.0.0/24" {
+		t.Fatalf("Subtract with nothing to remove = %q", prefixesString(got))
+	}
+}
+
+func TestEntriesOverlapping(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"140.82.112.0/20", "web 140.82.112.0/20"},
+		{"140.82.112.128/25", "web 140.82.112.0/20"},
+		{"128.0.0.0/1", "api 192.30.252.0/24, hooks 192.30.252.0/22, pages 185.199.108.0/22, web 140.82.112.0/20"},
+		{"192.30.0.0/16", "api 192.30.252.0/24, hooks 192.30.252.0/22"},
+		{"2001:db8::/32", "hooks 2001:db8:1::/48"},
+		{"8.8.8.0/24", ""},
+	}
+	for _, tt := range tests {
+		var parts []string
+		for _, entry := range meta.EntriesOverlapping(netip.MustParsePrefix(tt.prefix)) {
+			parts = append(parts, entry.Label+" "+entry.Prefix.String())
+		}
+		if got := strings.Join(parts, ", "); got != tt.want {
+			t.Errorf("EntriesOverlapping(%s) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixLengthHistogram(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	v4, v6 := meta.PrefixLengthHistogram()
+	if want := map[int]int{20: 1, 22: 2, 24: 1}; !maps.Equal(v4, want) {
+		t.Errorf("IPv4 histogram = %v, want %v", v4, want)
+	}
+	if want := map[int]int{48: 1}; !maps.Equal(v6, want) {
+		t.Errorf("IPv6 histogram = %v, want %v", v6, want)
+	}
+}
+
+func TestLabelOverlap(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	if got := meta.LabelOverlap("api", "hooks"); got.Int64() != 256 {
+		t.Fatalf("expected api/hooks overlap of 256, got %s", got)
+	}
+	if got := meta.LabelOverlap("hooks", "api"); got.Int64() != 256 {
+		t.Fatalf("overlap should be symmetric, got %s", got)
+	}
+	if got := meta.LabelOverlap("web", "api"); got.Sign() != 0 {
+		t.Fatalf("expected no web/api overlap, got %s", got)
+	}
+	if got := meta.LabelOverlap("hooks", "missing"); got.Sign() != 0 {
+		t.Fatalf("expected unknown label to overlap nothing, got %s", got)
+	}
+}
+
+func TestLabelPrefixDiff(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	tests := []struct {
+		a, b               string
+		onlyA, onlyB, both string
+	}{
+		{"api", "hooks", "", "192.30.253.0/24 192.30.254.0/23 2001:db8:1::/48", "192.30.252.0/24"},
+		{"HOOKS", "api", "192.30.253.0/24 192.30.254.0/23 2001:db8:1::/48", "", "192.30.252.0/24"},
+		{"web", "api", "140.82.112.0/20", "192.30.252.0/24", ""},
+		{"pages", "missing", "185.199.108.0/22", "", ""},
+		{"missing", "other", "", "", ""},
+	}
+	for _, tt := range tests {
+		onlyA, onlyB, both := meta.LabelPrefixDiff(tt.a, tt.b)
+		if got := prefixesString(onlyA); got != tt.onlyA {
+			t.Errorf("LabelPrefixDiff(%s, %s) onlyA = %q, want %q", tt.a, tt.b, got, tt.onlyA)
+		}
+		if got := prefixesString(onlyB); got != tt.onlyB {
+			t.Errorf("LabelPrefixDiff(%s, %s) onlyB = %q, want %q", tt.a, tt.b, got, tt.onlyB)
+		}
+		if got := prefixesString(both); got != tt.both {
+			t.Errorf("LabelPrefixDiff(%s, %s) both = %q, want %q", tt.a, tt.b, got, tt.both)
+		}
+	}
+}
+
+func TestComplementWithin(t *testing.T) {
+	meta := loadFixture(t, `{"pages": ["185.199.108.0/25"], "web": ["185.199.108.192/26"]}`)
+
+	cases := map[string]string{
+		// Half of the /24 is owned by pages and a quarter by web.
+		"185.199.108.0/24": "185.199.108.128/26",
+		"185.199.108.0/25": "",
+		"10.0.0.0/24":      "10.0.0.0/24",
+		"185.199.108.0/23": "185.199.108.128/26 185.199.109.0/24",
+	}
+	for in, want := range cases {
+		if got := prefixesString(meta.ComplementWithin(netip.MustParsePrefix(in))); got != want {
+			t.Errorf("ComplementWithin(%s) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestComplementWithinHalfOwned(t *testing.T) {
+	meta := loadFixture(t, `{"pages": ["185.199.108.0/25", "2001:db8::/48"]}`)
+
+	got := meta.ComplementWithin(netip.MustParsePrefix("185.199.108.0/24"))
+	if prefixesString(got) != "185.199.108.128/25" {
+		t.Fatalf("expected the uncovered upper half, got %v", got)
+	}
+	got = meta.ComplementWithin(netip.MustParsePrefix("2001:db8::/47"))
+	if prefixesString(got) != "2001:db8:1::/48" {
+		t.Fatalf("expected the uncovered IPv6 half, got %v", got)
+	}
+}
+
+func TestCoveredSupernets(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	// The /22s and the nested /24 share 192.30.0.0/16; the /20 lands in 140.82.0.0/16.
+	if got := prefixesString(meta.CoveredSupernets(16)); got != "140.82.0.0/16 185.199.0.0/16 192.30.0.0/16" {
+		t.Fatalf("CoveredSupernets(16) = %q", got)
+	}
+	// Entries broader than the requested length are returned whole.
+	if got := prefixesString(meta.CoveredSupernets(21)); got != "140.82.112.0/20 185.199.104.0/21 192.30.248.0/21" {
+		t.Fatalf("CoveredSupernets(21) = %q", got)
+	}
+	if got := prefixesString(meta.CoveredSupernets6(32)); got != "2001:db8::/32" {
+		t.Fatalf("CoveredSupernets6(32) = %q", got)
+	}
+	if got := meta.CoveredSupernets(33); got != nil {
+		t.Fatalf("CoveredSupernets(33) = %v, want nil", got)
+	}
+}
+
+func TestPerimeter(t *testing.T) {
+	cases := map[string]string{
+		// An isolated /24 is bordered by the last address below it and the first above.
+		`{"pages": ["185.199.108.0/24"]}`: "185.199.107.255 185.199.109.0",
+		// Touching prefixes, even ones that do not coalesce, share one perimeter.
+		`{"api": ["10.0.1.0/24"], "web": ["10.0.2.0/23"]}`: "10.0.0.255 10.0.4.0",
+		// No wraparound past either end of the address space.
+		`{"web": ["0.0.0.0/24", "255.255.255.0/24"]}`:       "0.0.1.0 255.255.254.255",
+		`{"hooks": ["192.30.252.0/22", "2001:db8:1::/48"]}`: "192.30.251.255 192.31.0.0 2001:db8:0:ffff:ffff:ffff:ffff:ffff 2001:db8:2::",
+	}
+	for raw, want := range cases {
+		var got []string
+		for _, addr := range loadFixture(t, raw).Perimeter() {
+			got = append(got, addr.String())
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("Perimeter of %s = %q, want %q", raw, strings.Join(got, " "), want)
+		}
+	}
+}
+
+func TestLabelsMatchRegardlessOfCase(t *testing.T) {
+	meta := loadFixture(t, `{"Pages": ["185.199.108.0/22"], "web": ["140.82.112.0/20"]}`)
+
+	if got := prefixesString(meta.LabelPrefixes("pages")); got != "185.199.108.0/22" {
+		t.Fatalf(`LabelPrefixes("pages") = %q`, got)
+	}
+	if label, ok := meta.ResolveLabel("PAGES"); !ok || label != "Pages" {
+		t.Fatalf(`ResolveLabel("PAGES") = %q, %v; want the document's spelling`, label, ok)
+	}
+	if got := meta.FilterLabels("pages").Labels(); len(got) != 1 || got[0] != "Pages" {
+		t.Fatalf(`FilterLabels("pages") kept %v`, got)
+	}
+	if got := meta.CompleteLabel("pa"); len(got) != 1 || got[0] != "Pages" {
+		t.Fatalf(`CompleteLabel("pa") = %v`, got)
+	}
+	if _, ok := meta.ResolveLabel("hooks"); ok {
+		t.Fatal(`ResolveLabel("hooks") matched a label that does not exist`)
+	}
+}
+
+func TestOwnedCount(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	cases := map[string]string{
+		"192.30.253.0/30": "4",
+		"192.30.252.0/23": "512",
+		// Nested api and hooks count once; web's /20 is all that overlaps 140.82.0.0/16.
+		"192.30.0.0/16": "1024",
+		"140.82.0.0/16": "4096",
+		"8.8.8.0/30":    "0",
+		"0.0.0.0/0":     "6144",
+		"2001:db8::/32": "1208925819614629174706176", // the /48, 2^80 addresses
+	}
+	for in, want := range cases {
+		if got := meta.OwnedCount(netip.MustParsePrefix(in)); got.String() != want {
+			t.Errorf("OwnedCount(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestMinimalCoveringPrefix(t *testing.T) {
+	cases := map[string]string{
+		"192.30.252.42":                            "192.30.252.42/32",
+		"192.30.252.42 192.30.252.43":              "192.30.252.42/31",
+		"192.30.252.1 192.30.255.254 192.30.253.7": "192.30.252.0/22",
+		"140.82.112.5 140.82.127.200":              "140.82.112.0/20",
+		"10.0.0.1 192.168.0.1":                     "0.0.0.0/0",
+		"2001:db8:1::1 2001:db8:1:ffff::1":         "2001:db8:1::/48",
+	}
+	for in, want := range cases {
+		var addrs []netip.Addr
+		for _, raw := range strings.Fields(in) {
+			addrs = append(addrs, netip.MustParseAddr(raw))
+		}
+		got, err := MinimalCoveringPrefix(addrs)
+		if err != nil || got.String() != want {
+			t.Errorf("MinimalCoveringPrefix(%s) = %v, %v; want %s", in, got, err, want)
+		}
+	}
+
+	if _, err := MinimalCoveringPrefix(nil); err == nil {
+		t.Error("expected an error for no addresses")
+	}
+	if _, err := MinimalCoveringPrefix([]netip.Addr{netip.MustParseAddr("192.30.252.1"), netip.MustParseAddr("2001:db8::1")}); err == nil {
+		t.Error("expected an error for mixed families")
+	}
+}
+
+func TestRelations(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+	cases := map[string]string{
+		"192.30.253.0/24":   "hooks 192.30.252.0/22 subset_of",
+		"192.30.252.0/22":   "api 192.30.252.0/24 superset_of, hooks 192.30.252.0/22 equal",
+		"192.30.248.0/21":   "api 192.30.252.0/24 superset_of, hooks 192.30.252.0/22 superset_of",
+		"140.82.112.5/32":   "web 140.82.112.0/20 subset_of",
+		"8.8.8.0/24":        "",
+		"2001:db8:1:2::/64": "hooks 2001:db8:1::/48 subset_of",
+	}
+	for in, want := range cases {
+		var parts []string
+		for _, rel := range meta.Relations(netip.MustParsePrefix(in)) {
+			parts = append(parts, rel.Entry.Label+" "+rel.Entry.Prefix.String()+" "+string(rel.Relation))
+		}
+		if got := strings.Join(parts, ", "); got != want {
+			t.Errorf("Relations(%s) = %q, want %q", in, got, want)
+		}
+	}
+}
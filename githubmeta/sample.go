@@ -0,0 +1,72 @@
+package githubmeta
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"net/netip"
+	"strings"
+)
+
+// SampleResult estimates the ownership of a prefix from randomly chosen addresses.
+type SampleResult struct {
+	// Prefix is the sampled network; host bits in the input are masked off.
+	Prefix netip.Prefix
+	// Total is the number of addresses in Prefix.
+	Total *big.Int
+	// Sampled is the number of addresses looked up.
+	Sampled int
+	// Owned is the number of sampled addresses matching at least one label.
+	Owned int
+	// LabelSets counts owned sampled addresses by the comma-joined set of labels
+	// they matched, as in CIDRResult.
+	LabelSets map[string]int
+}
+
+// OwnedPercent is the estimated percentage of Prefix owned by GitHub.
+func (r SampleResult) OwnedPercent() float64 {
+	if r.Sampled == 0 {
+		return 0
+	}
+	return 100 * float64(r.Owned) / float64(r.Sampled)
+}
+
+// SamplePrefix looks up n addresses drawn uniformly, with replacement, from p using
+// rnd, so a seeded source gives a reproducible sample. Unlike EvaluatePrefix it works
+// for prefixes of any size.
+func (m *MetaData) SamplePrefix(p netip.Prefix, n int, rnd *rand.Rand) (SampleResult, error) {
+	if !p.IsValid() {
+		return SampleResult{}, errors.New("invalid prefix")
+	}
+	if n < 1 {
+		return SampleResult{}, errors.New("sample size must be at least 1")
+	}
+
+	p = p.Masked()
+	res := SampleResult{
+		Prefix:    p,
+		Total:     PrefixSize(p),
+		Sampled:   n,
+		LabelSets: map[string]int{},
+	}
+	for i := 0; i < n; i++ {
+		labels := m.Lookup(RandomAddr(p, rnd))
+		if len(labels) > 0 {
+			res.Owned++
+			res.LabelSets[strings.Join(labels, ", ")]++
+		}
+	}
+	return res, nil
+}
+
+// RandomAddr returns an address drawn uniformly from p using rnd.
+func RandomAddr(p netip.Prefix, rnd *rand.Rand) netip.Addr {
+	p = p.Masked()
+	offset := new(big.Int).Rand(rnd, PrefixSize(p))
+	base := p.Addr().AsSlice()
+	v := new(big.Int).SetBytes(base)
+	v.Add(v, offset)
+	// The offset stays inside p, so the sum always fits in len(base) bytes.
+	addr, _ := netip.AddrFromSlice(v.FillBytes(make([]byte, len(base))))
+	return addr
+}
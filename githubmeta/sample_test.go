@@ -0,0 +1,54 @@
+package githubmeta
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestRandomAddrStaysInPrefix(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, raw := range []string{"10.0.0.0/8", "192.0.2.7/32", "2001:db8::/32", "0.0.0.0/0"} {
+		p := netip.MustParsePrefix(raw)
+		for i := 0; i < 100; i++ {
+			if addr := RandomAddr(p, rnd); !p.Contains(addr) {
+				t.Fatalf("RandomAddr(%s) = %s, outside the prefix", raw, addr)
+			}
+		}
+	}
+}
+
+func TestSamplePrefixIsReproducible(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+	p := netip.MustParsePrefix("192.30.0.0/16")
+
+	first, err := meta.SamplePrefix(p, 200, rand.New(rand.NewSource(42)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := meta.SamplePrefix(p, 200, rand.New(rand.NewSource(42)))
+	if first.Owned != second.Owned || first.LabelSets["hooks"] != second.LabelSets["hooks"] {
+		t.Fatalf("same seed gave different samples: %+v vs %+v", first, second)
+	}
+
+	a := RandomAddr(p, rand.New(rand.NewSource(7)))
+	b := RandomAddr(p, rand.New(rand.NewSource(7)))
+	if a != b {
+		t.Fatalf("same seed picked %s and %s", a, b)
+	}
+}
+
+func TestSamplePrefixInsideHooks(t *testing.T) {
+	meta := loadFixture(t, fixtureMeta)
+
+	res, err := meta.SamplePrefix(netip.MustParsePrefix("192.30.254.0/23"), 100, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OwnedPercent() != 100 || res.LabelSets["hooks"] != 100 || res.Total.Int64() != 512 {
+		t.Fatalf("expected every sampled address to be hooks-owned, got %+v", res)
+	}
+	if _, err := meta.SamplePrefix(netip.MustParsePrefix("192.30.254.0/23"), 0, rand.New(rand.NewSource(1))); err == nil {
+		t.Fatal("expected an error for a zero sample size")
+	}
+}
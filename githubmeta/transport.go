@@ -0,0 +1,62 @@
+package githubmeta
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// userAgent identifies every request made by this package.
+const userAgent = "cidr-calculator-github/1.0"
+
+// defaultClient performs requests when FetchOptions.Client is nil.
+var defaultClient = &http.Client{Transport: newTransport(0, nil)}
+
+// newTransport returns the transport used by clients built inside this package. It
+// starts from http.DefaultTransport and always routes through http.ProxyFromEnvironment,
+// so HTTP_PROXY, HTTPS_PROXY and NO_PROXY keep working when TLS or timeout settings
+// are customised here, unless proxy is set to route every request through it
+// instead. A positive connectTimeout bounds dialing each connection.
+func newTransport(connectTimeout time.Duration, proxy *url.URL) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	if connectTimeout > 0 {
+		t.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return t
+}
+
+// HTTPClient returns the client the options fetch with: Client when set, otherwise
+// one that honours Proxy and ConnectTimeout, falling back to the package default.
+// Callers use it to download related documents under the same network settings.
+func (o FetchOptions) HTTPClient() *http.Client {
+	switch {
+	case o.Client != nil:
+		return o.Client
+	case o.ConnectTimeout > 0 || o.Proxy != nil:
+		return &http.Client{Transport: newTransport(o.ConnectTimeout, o.Proxy)}
+	}
+	return defaultClient
+}
+
+// NewRequest returns a GET request for rawURL carrying the User-Agent of meta data
+// requests and decorated by RequestFunc, for sending with HTTPClient.
+func (o FetchOptions) NewRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if o.RequestFunc != nil {
+		o.RequestFunc(req)
+		if req.Context() != ctx {
+			req = req.WithContext(ctx)
+		}
+	}
+	return req, nil
+}
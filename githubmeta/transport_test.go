@@ -0,0 +1,117 @@
+package githubmeta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+const testProxy = "http://proxy.example:3128"
+
+// TestMain sets the proxy variables before any test runs: http.ProxyFromEnvironment
+// reads the environment only once per process. Requests to the loopback httptest
+// servers used elsewhere are never proxied.
+func TestMain(m *testing.M) {
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		os.Setenv(name, testProxy)
+	}
+	for _, name := range []string{"NO_PROXY", "no_proxy", "REQUEST_METHOD"} {
+		os.Unsetenv(name)
+	}
+	os.Exit(m.Run())
+}
+
+func TestNewTransportHonorsProxyEnvironment(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, metaURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, transport := range map[string]*http.Transport{
+		"newTransport":  newTransport(time.Second, nil),
+		"defaultClient": defaultClient.Transport.(*http.Transport),
+	} {
+		if transport.Proxy == nil {
+			t.Fatalf("%s: expected a Proxy func", name)
+		}
+		proxy, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if proxy == nil || proxy.String() != testProxy {
+			t.Fatalf("%s: request to %s proxied via %v, want %s", name, metaURL, proxy, testProxy)
+		}
+	}
+}
+
+func TestNewTransportExplicitProxyOverridesEnvironment(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, metaURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &url.URL{Scheme: "http", Host: "corp-proxy.example:8080"}
+	proxy, err := newTransport(0, want).Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy == nil || proxy.String() != want.String() {
+		t.Fatalf("request proxied via %v, want %s", proxy, want)
+	}
+}
+
+func TestConnectTimeoutDoesNotLimitSlowBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte(sampleMeta))
+	}))
+	defer srv.Close()
+
+	oldEndpoint := metaEndpoint
+	metaEndpoint = srv.URL
+	defer func() {
+		metaEndpoint = oldEndpoint
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	meta, err := FetchWithOptions(ctx, FetchOptions{ConnectTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("slow body failed under a short connect timeout: %v", err)
+	}
+	if len(meta.Entries()) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(meta.Entries()))
+	}
+}
+
+func TestFetchOptionsHTTPClientAndRequest(t *testing.T) {
+	custom := &http.Client{}
+	if got := (FetchOptions{Client: custom, Proxy: &url.URL{Host: "ignored"}}).HTTPClient(); got != custom {
+		t.Fatalf("expected the configured Client, got %v", got)
+	}
+	if got := (FetchOptions{}).HTTPClient(); got != defaultClient {
+		t.Fatalf("expected the default client, got %v", got)
+	}
+
+	want := &url.URL{Scheme: "http", Host: "corp-proxy.example:8080"}
+	opts := FetchOptions{
+		Proxy:       want,
+		RequestFunc: func(req *http.Request) { req.Header.Set("X-Test", "decorated") },
+	}
+	req, err := opts.NewRequest(context.Background(), "https://allowlist.example/ranges.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("User-Agent") != userAgent || req.Header.Get("X-Test") != "decorated" {
+		t.Fatalf("request not prepared like meta requests: %v", req.Header)
+	}
+	proxy, err := opts.HTTPClient().Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxy == nil || proxy.String() != want.String() {
+		t.Fatalf("request proxied via %v (%v), want %s", proxy, err, want)
+	}
+}
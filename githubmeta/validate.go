@@ -0,0 +1,60 @@
+package githubmeta
+
+import "fmt"
+
+// Anomaly is a suspicious entry reported by Validate.
+type Anomaly struct {
+	Entry
+	Problem string
+}
+
+// Validate reports entries that are unlikely to be intended: prefixes covering a
+// whole address family, prefixes with host bits set, and prefixes listed more than
+// once under the same label, including the repeats Parse dropped. Anomalies are
+// returned in the order of Entries.
+func (m *MetaData) Validate() []Anomaly {
+	if m == nil {
+		return nil
+	}
+	all := append(m.Entries(), m.duplicates...)
+	sortEntries(all)
+
+	var anomalies []Anomaly
+	seen := make(map[Entry]bool, len(all))
+	for _, entry := range all {
+		switch {
+		case entry.Prefix.Bits() == 0:
+			anomalies = append(anomalies, Anomaly{entry, "covers the entire address family"})
+		case entry.Prefix.Masked() != entry.Prefix:
+			anomalies = append(anomalies, Anomaly{entry, fmt.Sprintf("has host bits set (network is %s)", entry.Prefix.Masked())})
+		}
+		masked := Entry{Label: entry.Label, Prefix: entry.Prefix.Masked()}
+		if seen[masked] {
+			anomalies = append(anomalies, Anomaly{entry, "is listed more than once"})
+		}
+		seen[masked] = true
+	}
+	return anomalies
+}
+
+// Canonical returns a copy of m with every prefix masked, duplicates within a label
+// removed, and entries sorted as Parse sorts them. Fields without CIDRs are kept, so
+// marshaling the result gives a normalized meta.json that re-parses to the same entries.
+func (m *MetaData) Canonical() *MetaData {
+	if m == nil {
+		return nil
+	}
+	seen := make(map[Entry]bool, len(m.entries))
+	var entries []Entry
+	for _, entry := range m.entries {
+		entry.Prefix = entry.Prefix.Masked()
+		if !seen[entry] {
+			seen[entry] = true
+			entries = append(entries, entry)
+		}
+	}
+	sortEntries(entries)
+	canonical := newMetaData(entries)
+	canonical.extra = m.extra
+	return canonical
+}
@@ -0,0 +1,76 @@
+package githubmeta
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsAnomalies(t *testing.T) {
+	// Parse rather than loadFixture: the repeats Parse drops are kept for Validate.
+	meta, err := Parse(strings.NewReader(`{"web": ["0.0.0.0/0", "140.82.112.5/20", "140.82.112.0/20"], "api": ["192.30.252.0/24"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, a := range meta.Validate() {
+		got = append(got, a.Label+" "+a.Prefix.String()+": "+a.Problem)
+	}
+	want := []string{
+		"web 0.0.0.0/0: covers the entire address family",
+		"web 140.82.112.5/20: has host bits set (network is 140.82.112.0/20)",
+		"web 140.82.112.5/20: is listed more than once",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Validate() = %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalReparsesIdentically(t *testing.T) {
+	meta, err := Parse(strings.NewReader(`{"web": ["140.82.112.5/20", "140.82.112.0/20"], "api": ["192.30.252.0/24"], "ssh_keys": ["ssh-ed25519 AAAA"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := meta.Canonical()
+	if len(canonical.Validate()) != 0 {
+		t.Fatalf("canonical form still has anomalies: %v", canonical.Validate())
+	}
+
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Entries(), canonical.Entries()) || !strings.Contains(string(data), "ssh_keys") {
+		t.Fatalf("canonical document %s did not re-parse identically", data)
+	}
+}
+
+func TestParseCollapsesRepeatedPrefixes(t *testing.T) {
+	meta, err := Parse(strings.NewReader(`{"hooks": ["192.30.252.0/22", "192.30.252.0/22", "192.30.252.1/22"], "api": ["192.30.252.0/22"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One hooks entry survives, and the same prefix under api is a distinct entry.
+	want := []Entry{
+		{Label: "api", Prefix: netip.MustParsePrefix("192.30.252.0/22")},
+		{Label: "hooks", Prefix: netip.MustParsePrefix("192.30.252.0/22")},
+	}
+	if got := meta.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Entries() = %v, want %v", got, want)
+	}
+	if total, _, _ := meta.PrefixCount(); total != 2 {
+		t.Fatalf("PrefixCount() = %d, want 2", total)
+	}
+	if got := len(meta.Validate()); got != 3 {
+		t.Fatalf("Validate() reported %d anomalies, want the two repeats plus host bits: %v", got, meta.Validate())
+	}
+}
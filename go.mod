@@ -1,3 +1,3 @@
 module github.com/dav1dc-github/cidr-calculator-github
 
-go 1.22
+go 1.23
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// interfaceAddr is an address assigned to a local network interface.
+type interfaceAddr struct {
+	iface string
+	addr  netip.Addr
+}
+
+// localInterfaceAddrs lists the addresses of the machine's network interfaces.
+func localInterfaceAddrs() ([]interfaceAddr, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var addrs []interfaceAddr
+	for _, iface := range ifaces {
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", iface.Name, err)
+		}
+		for _, a := range ifaceAddrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if addr, ok := netip.AddrFromSlice(ipnet.IP); ok {
+				addrs = append(addrs, interfaceAddr{iface.Name, addr.Unmap()})
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// checkInterfaces implements --check-interfaces: it looks up every global unicast
+// address of the local interfaces and reports the ones GitHub owns.
+func (a *app) checkInterfaces(meta *githubmeta.MetaData) error {
+	list := a.interfaceAddrs
+	if list == nil {
+		list = localInterfaceAddrs
+	}
+	addrs, err := list()
+	if err != nil {
+		return fmt.Errorf("list interfaces: %w", err)
+	}
+	checked, owned := 0, 0
+	for _, ia := range addrs {
+		// Loopback, link-local and multicast addresses can never be GitHub's.
+		if !ia.addr.IsGlobalUnicast() {
+			continue
+		}
+		checked++
+		if labels := meta.Lookup(ia.addr); len(labels) > 0 {
+			owned++
+			fmt.Fprintf(a.stdout, "%s: %s -> owned by GitHub (%s)\n", ia.iface, ia.addr, strings.Join(labels, ", "))
+		}
+	}
+	if owned == 0 {
+		fmt.Fprintf(a.stdout, "None of %d local interface addresses is owned by GitHub.\n", checked)
+	}
+	return nil
+}
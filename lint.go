@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// runLint implements the lint subcommand: it validates an archived meta.json offline
+// and, with --fix, rewrites it in canonical form. The exit status is 1 while
+// anomalies remain.
+func (a *app) runLint(ctx context.Context, args []string) int {
+	fs := newFlagSet("lint", a.stderr)
+	fix := fs.Bool("fix", false, "rewrite FILE in canonical form: prefixes masked, duplicates dropped, labels and prefixes sorted")
+	if err := fs.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if fs.NArg() != 1 {
+		return usageExitCode(usageError(fs, "lint expects exactly one FILE"))
+	}
+	path := fs.Arg(0)
+
+	meta, err := githubmeta.LoadFromFile(path)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+	anomalies := meta.Validate()
+	fmt.Fprintf(a.stdout, "%s: %s across %d labels, %d anomalies\n", path, describeCount(meta), len(meta.Labels()), len(anomalies))
+	for _, anomaly := range anomalies {
+		fmt.Fprintf(a.stdout, "  %s %s %s\n", anomaly.Label, anomaly.Prefix, anomaly.Problem)
+	}
+
+	if *fix {
+		canonical := meta.Canonical()
+		data, err := json.MarshalIndent(canonical, "", "  ")
+		if err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		anomalies = canonical.Validate()
+		fmt.Fprintf(a.stdout, "Rewrote %s in canonical form; %d anomalies remain.\n", path, len(anomalies))
+	}
+	if len(anomalies) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,70 +1,166 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/netip"
+	"io"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
-
-	"github.com/dav1dc-github/cidr-calculator-github/internal/githubmeta"
 )
 
+// fetchTimeout is the default --timeout for each request to the meta endpoint.
+const fetchTimeout = 15 * time.Second
+
 func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
-	fmt.Println("Fetching GitHub IP ranges...")
-	meta, err := githubmeta.Fetch(ctx, nil)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	a := &app{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	code := a.run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// app carries the I/O streams and network client used by the CLI so tests can substitute them.
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	client *http.Client
+	// resolver performs reverse DNS lookups for --ptr; nil uses net.DefaultResolver.
+	resolver ptrResolver
+	// asnPrefixes backs --asn; nil uses githubASNPrefixes.
+	asnPrefixes []asnPrefix
+	// interfaceAddrs lists local addresses for --check-interfaces; nil uses
+	// localInterfaceAddrs.
+	interfaceAddrs func() ([]interfaceAddr, error)
+}
+
+// ptrResolver is the subset of *net.Resolver needed for --ptr.
+type ptrResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// command is a subcommand verb. Each handler parses only its own flags from args,
+// which excludes the verb itself, and returns the process exit code.
+type command struct {
+	name    string
+	summary string
+	run     func(a *app, ctx context.Context, args []string) int
+}
+
+// defaultCommand runs when the first argument is not a known verb, so
+// "cidr-calculator-github 192.30.252.44" keeps working.
+const defaultCommand = "check"
+
+var commands = []command{
+	{"check", "check addresses and CIDRs against GitHub's ranges (default)", (*app).runCheck},
+	{"stats", "summarise the ranges: prefixes per label, label overlap, complements", (*app).runStats},
+	{"emit", "print the coalesced ranges of all or selected labels", (*app).runEmit},
+	{"diff", "compare two meta.json snapshots, or watch the live ranges for changes", (*app).runDiff},
+	{"lint", "validate an archived meta.json and optionally canonicalize it", (*app).runLint},
+	{"serve", "answer lookups over HTTP", (*app).runServe},
+	{"cache", "inspect or clear the on-disk cache", (*app).runCache},
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
-	fmt.Printf("Loaded %d CIDR blocks from GitHub.\n", len(meta.Entries()))
+	return command{}, false
+}
 
-	args := os.Args[1:]
+func (a *app) run(ctx context.Context, args []string) int {
 	if len(args) > 0 {
-		for _, arg := range args {
-			evaluateInput(meta, arg)
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			a.printUsage()
+			return 0
+		}
+		if cmd, ok := lookupCommand(args[0]); ok {
+			return cmd.run(a, ctx, args[1:])
 		}
-		return
 	}
+	if action, rest, ok := legacyCacheFlag(args); ok {
+		fmt.Fprintf(a.stderr, "warning: --cache-%s is deprecated; use 'cidr-calculator-github cache %s'\n", action, action)
+		return a.runCache(ctx, append(rest, action))
+	}
+	cmd, _ := lookupCommand(defaultCommand)
+	return cmd.run(a, ctx, args)
+}
 
-	fmt.Println("Enter an IP address to check (type 'exit' to quit):")
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Print("> ")
-		if !scanner.Scan() {
-			if err := scanner.Err(); err != nil {
-				fmt.Fprintf(os.Stderr, "input error: %v\n", err)
-			}
+// legacyCacheFlag finds the --cache-info or --cache-clear flag that predates the
+// cache subcommand, returning its action and the other arguments so the call can
+// be forwarded to "cache ACTION".
+func legacyCacheFlag(args []string) (action string, rest []string, ok bool) {
+	for i, arg := range args {
+		if arg == "--" {
 			break
 		}
-		input := strings.TrimSpace(scanner.Text())
-		if input == "" {
+		if !strings.HasPrefix(arg, "-") {
 			continue
 		}
-		if strings.EqualFold(input, "exit") || strings.EqualFold(input, "quit") {
-			break
+		switch strings.TrimLeft(arg, "-") {
+		case "cache-info", "cache-info=true":
+			action = "info"
+		case "cache-clear", "cache-clear=true":
+			action = "clear"
+		default:
+			continue
 		}
-		evaluateInput(meta, input)
+		rest = append(slices.Clone(args[:i]), args[i+1:]...)
+		return action, rest, true
 	}
+	return "", nil, false
 }
 
-func evaluateInput(meta *githubmeta.MetaData, raw string) {
-	addr, err := netip.ParseAddr(raw)
-	if err != nil {
-		fmt.Printf("%s -> invalid IP address (%v)\n", raw, err)
-		return
+func (a *app) printUsage() {
+	fmt.Fprintln(a.stderr, "Usage: cidr-calculator-github [COMMAND] [flags] [args]")
+	fmt.Fprintln(a.stderr)
+	fmt.Fprintln(a.stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(a.stderr, "  %-6s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(a.stderr)
+	fmt.Fprintf(a.stderr, "Without a command, arguments are passed to %q. Run 'cidr-calculator-github COMMAND -h' for its flags.\n", defaultCommand)
+}
 
-	labels := meta.Lookup(addr)
-	if len(labels) == 0 {
-		fmt.Printf("%s -> not owned by GitHub (based on current meta data)\n", addr)
-		return
+// newFlagSet returns the flag set for a subcommand, reporting errors to stderr.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("cidr-calculator-github "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// usageError reports a flag validation problem the same way flag.Parse reports parse errors.
+func usageError(fs *flag.FlagSet, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	fmt.Fprintln(fs.Output(), err)
+	fs.Usage()
+	return err
+}
+
+// usageExitCode maps a flag parsing error to the exit code: 0 for -h, 2 otherwise.
+func usageExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
 	}
+	return 2
+}
+
+// warnf prints a non-fatal problem to stderr.
+func (a *app) warnf(format string, args ...any) {
+	fmt.Fprintf(a.stderr, "warning: "+format+"\n", args...)
+}
 
-	fmt.Printf("%s -> owned by GitHub (%s)\n", addr, strings.Join(labels, ", "))
+// tracef prints an --explain-cache line to stderr.
+func (a *app) tracef(format string, args ...any) {
+	fmt.Fprintf(a.stderr, "cache: "+format+"\n", args...)
 }
@@ -0,0 +1,1544 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+const testMeta = `{
+  "hooks": ["192.30.252.0/22", "2001:db8:1::/48"],
+  "web": ["140.82.112.0/20"],
+  "api": ["192.30.252.0/24"],
+  "pages": ["185.199.108.0/22"]
+}`
+
+func TestRunDispatchesSubcommands(t *testing.T) {
+	saved := commands
+	t.Cleanup(func() { commands = saved })
+
+	var gotName string
+	var gotArgs []string
+	commands = make([]command, len(saved))
+	for i, cmd := range saved {
+		name := cmd.name
+		commands[i] = command{name: name, run: func(_ *app, _ context.Context, args []string) int {
+			gotName, gotArgs = name, args
+			return 0
+		}}
+	}
+
+	tests := []struct {
+		args     []string
+		wantName string
+		wantArgs []string
+	}{
+		{[]string{"check", "192.30.252.44"}, "check", []string{"192.30.252.44"}},
+		{[]string{"stats", "--label-overlap", "api", "hooks"}, "stats", []string{"--label-overlap", "api", "hooks"}},
+		{[]string{"emit", "hooks"}, "emit", []string{"hooks"}},
+		{[]string{"diff", "old.json", "new.json"}, "diff", []string{"old.json", "new.json"}},
+		{[]string{"cache", "info"}, "cache", []string{"info"}},
+		{[]string{"192.30.252.44"}, "check", []string{"192.30.252.44"}},
+		{[]string{"--format", "json", "8.8.8.8"}, "check", []string{"--format", "json", "8.8.8.8"}},
+		{nil, "check", nil},
+	}
+	for _, tt := range tests {
+		gotName, gotArgs = "", nil
+		a, _, _ := newTestApp("")
+		if code := a.run(context.Background(), tt.args); code != 0 {
+			t.Fatalf("run(%q) exited %d", tt.args, code)
+		}
+		if gotName != tt.wantName || strings.Join(gotArgs, " ") != strings.Join(tt.wantArgs, " ") {
+			t.Errorf("run(%q) dispatched to %s %q, want %s %q", tt.args, gotName, gotArgs, tt.wantName, tt.wantArgs)
+		}
+	}
+}
+
+func TestRunHelpListsCommands(t *testing.T) {
+	a, _, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"help"}); code != 0 {
+		t.Fatalf("help exited %d", code)
+	}
+	for _, cmd := range commands {
+		if !strings.Contains(stderr.String(), "  "+cmd.name+" ") {
+			t.Errorf("usage does not mention %q: %q", cmd.name, stderr)
+		}
+	}
+}
+
+func newTestApp(stdin string) (*app, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return &app{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}, &stdout, &stderr
+}
+
+// redirectTransport sends every request to target regardless of the requested host.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// serveMeta starts a server answering every request with body and returns a client routed to it.
+func serveMeta(t *testing.T, body string) *http.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"test"`)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: redirectTransport{target: target}}
+}
+
+func TestRunLegacyCacheFlagsForwardToCacheCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte(testMeta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--cache-dir", dir, "--cache-info"}); code != 0 {
+		t.Fatalf("--cache-info exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "Cache directory: "+dir) {
+		t.Fatalf("expected cache info, got %q", stdout)
+	}
+	if !strings.Contains(stderr.String(), "warning: --cache-info is deprecated; use 'cidr-calculator-github cache info'") {
+		t.Fatalf("expected a deprecation warning, got %q", stderr)
+	}
+
+	a, stdout, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"-cache-clear", "--cache-dir", dir}); code != 0 {
+		t.Fatalf("-cache-clear exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "Cleared cache in "+dir) || !strings.Contains(stderr.String(), "use 'cidr-calculator-github cache clear'") {
+		t.Fatalf("expected the cache to be cleared with a warning, got %q / %q", stdout, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "meta.json")); !os.IsNotExist(err) {
+		t.Fatalf("meta.json should be gone, got %v", err)
+	}
+}
+
+func TestRunCacheClearThenInfo(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", root)
+	t.Setenv("HOME", root)
+
+	dir := filepath.Join(root, "cidr-calculator-github")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{"meta.json": testMeta, "meta.etag": `"v1"`} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"cache", "info"}); code != 0 {
+		t.Fatalf("cache info exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), `meta.etag: 4 bytes ("v1")`) {
+		t.Fatalf("expected etag details, got %q", stdout)
+	}
+
+	a, stdout, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"cache", "clear"}); code != 0 {
+		t.Fatalf("cache clear exited %d: %s", code, stderr)
+	}
+	if code := a.run(context.Background(), []string{"cache", "info"}); code != 0 {
+		t.Fatalf("cache info exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "meta.json: not present") || !strings.Contains(out, "meta.etag: not present") {
+		t.Fatalf("expected cache files to be reported gone, got %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "meta.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected meta.json to be removed, stat err = %v", err)
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"cache", "clear"}); code != 0 {
+		t.Fatalf("repeated cache clear exited %d: %s", code, stderr)
+	}
+}
+
+func TestRunCacheDirRoutesFetchThroughDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "cache")
+	a, stdout, stderr := newTestApp("")
+	a.client = serveMeta(t, testMeta)
+
+	if code := a.run(context.Background(), []string{"--cache-dir", dir, "140.82.112.5"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "140.82.112.5 -> owned by GitHub (web)") {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "meta.json")); err != nil {
+		t.Fatalf("expected meta.json in %s: %v", dir, err)
+	}
+}
+
+func TestRunCacheDirRejectsUncreatablePath(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, _, stderr := newTestApp("")
+	a.client = serveMeta(t, testMeta)
+
+	if code := a.run(context.Background(), []string{"--cache-dir", filepath.Join(file, "cache"), "140.82.112.5"}); code != 1 {
+		t.Fatalf("expected exit 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "not usable") {
+		t.Fatalf("expected cache dir error, got %q", stderr)
+	}
+}
+
+func writeTestFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunAsOfSnapshotWithInput(t *testing.T) {
+	snapshot := writeTestFile(t, "meta-2024-01-01.json", testMeta)
+	input := writeTestFile(t, "ips.txt", "# incident addresses\n192.30.252.44\n\n8.8.8.8\n")
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--input", input}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "192.30.252.44 -> owned by GitHub (api, hooks)") {
+		t.Fatalf("expected snapshot match, got %q", out)
+	}
+	if !strings.Contains(out, "8.8.8.8 -> not owned by GitHub (based on snapshot "+snapshot+")") {
+		t.Fatalf("expected snapshot disclaimer, got %q", out)
+	}
+	if strings.Contains(out, "current meta data") {
+		t.Fatalf("live data disclaimer should not appear for snapshots: %q", out)
+	}
+}
+
+func TestRunSplitsPastedList(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "192.30.252.42, 140.82.112.5 8.8.8.8", "192.30.252.0/23"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"192.30.252.42 -> owned by GitHub (api, hooks)\n",
+		"140.82.112.5 -> owned by GitHub (web)\n",
+		"8.8.8.8 -> not owned by GitHub (",
+		"192.30.252.0/23 -> 512 of 512 addresses owned by GitHub\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "invalid") {
+		t.Fatalf("pasted list was not split: %q", out)
+	}
+}
+
+func TestRunLabelsOnly(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--labels-only", "192.30.252.42"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if got := stdout.String(); got != "api\nhooks\n" {
+		t.Fatalf("labels-only printed %q, want %q", got, "api\nhooks\n")
+	}
+
+	a, stdout, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--labels-only", "8.8.8.8"}); code != 1 {
+		t.Fatalf("expected exit 1 for a miss, got %d", code)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no output for a miss, got %q", stdout)
+	}
+
+	a, stdout, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--labels-only", "192.30.252.0/23"}); code != 0 {
+		t.Fatalf("expected exit 0 for an owned CIDR, got %d", code)
+	}
+	if got := stdout.String(); got != "api\nhooks\n" {
+		t.Fatalf("labels-only printed %q for a CIDR, want the distinct labels", got)
+	}
+}
+
+func TestRunPrefixLengthFilter(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--min-prefix", "24", "--labels-only", "192.30.252.42"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if got := stdout.String(); got != "api\n" {
+		t.Fatalf("--min-prefix 24 printed %q, want only the /24 api label", got)
+	}
+
+	a, _, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--min-prefix", "25", "--max-prefix", "24", "192.30.252.42"}); code != 2 {
+		t.Fatalf("expected usage error for inverted bounds, got exit %d", code)
+	}
+}
+
+func TestRunNoDisclaimer(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "8.8.8.8", "10.0.0.0/30"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "8.8.8.8 -> not owned by GitHub (based on snapshot "+snapshot+")\n") {
+		t.Fatalf("expected the disclaimer by default, got %q", stdout)
+	}
+
+	a, stdout, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--no-disclaimer", "8.8.8.8", "10.0.0.0/30"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "8.8.8.8 -> not owned by GitHub\n") || strings.Contains(stdout.String(), "based on") {
+		t.Fatalf("expected no disclaimer with --no-disclaimer, got %q", stdout)
+	}
+}
+
+func TestRunSortOutput(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	batch := []string{"--as-of", snapshot, "--format", "json", "--fields", "input", "8.8.8.8", "bogus", "192.30.252.42", "140.82.112.5", "10.0.0.1", "2001:db8:1::1", "9.0.0.0/30"}
+
+	for order, want := range map[string]string{
+		"input":     "8.8.8.8 bogus 192.30.252.42 140.82.112.5 10.0.0.1 2001:db8:1::1 9.0.0.0/30",
+		"address":   "8.8.8.8 9.0.0.0/30 10.0.0.1 140.82.112.5 192.30.252.42 2001:db8:1::1 bogus",
+		"ownership": "192.30.252.42 140.82.112.5 2001:db8:1::1 8.8.8.8 10.0.0.1 9.0.0.0/30 bogus",
+	} {
+		a, stdout, stderr := newTestApp("")
+		if code := a.run(context.Background(), append([]string{"--sort-output", order}, batch...)); code != 0 {
+			t.Fatalf("--sort-output %s exited %d: %s", order, code, stderr)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+			var res struct{ Input string }
+			if err := json.Unmarshal([]byte(line), &res); err != nil {
+				t.Fatalf("decode %q: %v", line, err)
+			}
+			got = append(got, res.Input)
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("--sort-output %s ordered %q, want %q", order, strings.Join(got, " "), want)
+		}
+	}
+
+	a, _, _ := newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--sort-output", "size", "8.8.8.8"}); code != 2 {
+		t.Fatalf("expected usage error for an unknown order, got exit %d", code)
+	}
+}
+
+func TestRunCover(t *testing.T) {
+	a, stdout, stderr := newTestApp("# from the access log\n192.30.255.254\n192.30.253.7\n")
+	if code := a.run(context.Background(), []string{"--cover", "--input", "-", "192.30.252.1"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if stdout.String() != "192.30.252.0/22\n" {
+		t.Fatalf("expected 192.30.252.0/22, got %q", stdout)
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--cover", "192.30.252.1", "2001:db8::1"}); code != 1 || !strings.Contains(stderr.String(), "mixed address families") {
+		t.Fatalf("expected an error for mixed families, got %d: %s", code, stderr)
+	}
+}
+
+func TestRunMergeTagSource(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	ghes := writeTestFile(t, "ghes.json", `{"web": ["140.82.112.0/24"], "api": ["10.0.1.0/24"]}`)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--merge", "ghes.example=" + ghes, "--tag-source", "140.82.112.0/23", "10.0.1.1"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"  web@ghes.example, web@github.com: 256 addresses\n",
+		"  web@github.com: 256 addresses\n",
+		"10.0.1.1 -> owned by GitHub (api@ghes.example)\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("expected %q in %q", want, stdout)
+		}
+	}
+
+	a, _, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--tag-source", "10.0.1.1"}); code != 2 {
+		t.Fatalf("expected usage error for --tag-source without --merge, got %d", code)
+	}
+}
+
+func TestRunGroupByFamily(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--group-by-family", "2001:db8:1::1", "8.8.8.8", "192.30.252.42", "::1", "140.82.112.5"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	v4, v6 := strings.Index(out, "IPv4:\n"), strings.Index(out, "IPv6:\n")
+	if v4 < 0 || v6 < v4 {
+		t.Fatalf("expected an IPv4 section followed by an IPv6 section, got %q", out)
+	}
+	for _, want := range []string{
+		"IPv4 subtotal: 3 checked: 2 owned, 1 not owned, 0 invalid\n",
+		"IPv6 subtotal: 2 checked: 1 owned, 1 not owned, 0 invalid\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+	if i := strings.Index(out, "2001:db8:1::1 ->"); i < v6 {
+		t.Errorf("expected 2001:db8:1::1 under IPv6, got %q", out)
+	}
+	if strings.Contains(out, "Invalid:") {
+		t.Errorf("expected no Invalid section without invalid input, got %q", out)
+	}
+
+	a, _, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--group-by-family", "--format", "json", "8.8.8.8"}); code != 2 {
+		t.Fatalf("expected usage error with --format json, got exit %d", code)
+	}
+}
+
+func TestRunCountPrintsBareIntegers(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--count", "192.30.253.0/30", "8.8.8.0/30", "192.30.252.42", "8.8.8.8", "140.82.0.0/16"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	// The /16 is over --limit but counted from the overlapping prefixes.
+	if got, want := stdout.String(), "4\n0\n1\n0\n4096\n"; got != want {
+		t.Fatalf("--count printed %q, want %q", got, want)
+	}
+}
+
+func TestRunLintReportsAndFixes(t *testing.T) {
+	path := writeTestFile(t, "meta.json", `{"web": ["140.82.112.5/20", "0.0.0.0/0"], "api": ["192.30.252.0/24", "192.30.252.0/24"]}`)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"lint", path}); code != 1 {
+		t.Fatalf("expected exit 1 with anomalies, got %d: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"3 anomalies",
+		"  web 0.0.0.0/0 covers the entire address family\n",
+		"  web 140.82.112.5/20 has host bits set (network is 140.82.112.0/20)\n",
+		"  api 192.30.252.0/24 is listed more than once\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected %q in lint output, got %q", want, stdout)
+		}
+	}
+
+	a, stdout, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"lint", "--fix", path}); code != 1 {
+		t.Fatalf("expected exit 1 while the /0 remains, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "1 anomalies remain") {
+		t.Fatalf("unexpected fix output %q", stdout)
+	}
+	fixed, err := githubmeta.LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range fixed.Entries() {
+		got = append(got, entry.Label+" "+entry.Prefix.String())
+	}
+	if want := []string{"api 192.30.252.0/24", "web 0.0.0.0/0", "web 140.82.112.0/20"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fixed file has entries %q, want %q", got, want)
+	}
+	if again := fixed.Canonical(); !reflect.DeepEqual(again.Entries(), fixed.Entries()) {
+		t.Fatal("fixed file is not in canonical form")
+	}
+}
+
+func TestRunBitmaskExit(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	for _, tt := range []struct {
+		inputs []string
+		want   int
+	}{
+		{[]string{"192.30.252.42", "140.82.112.5"}, 1},
+		{[]string{"8.8.8.8", "1.1.1.1"}, 2},
+		{[]string{"192.30.252.42", "8.8.8.8"}, 3},
+		{[]string{"192.30.252.42", "not-an-ip"}, 5},
+		{[]string{"192.30.252.0/22"}, 1},
+		{[]string{"192.30.252.0/21"}, 3},
+	} {
+		a, _, stderr := newTestApp("")
+		args := append([]string{"--as-of", snapshot, "--bitmask-exit"}, tt.inputs...)
+		if code := a.run(context.Background(), args); code != tt.want {
+			t.Errorf("%v: exit %d, want %d (%s)", tt.inputs, code, tt.want, stderr)
+		}
+	}
+}
+
+func TestSourceTimeouts(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want time.Duration
+	}{
+		{nil, fetchTimeout},
+		{[]string{"--read-timeout", "5s"}, 5 * time.Second},
+		{[]string{"--read-timeout", "1m", "--timeout", "20s"}, 20 * time.Second},
+	} {
+		var src sourceOptions
+		fs := newFlagSet("test", io.Discard)
+		src.addFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := src.parsed(fs); err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got := src.requestTimeout(); got != tt.want {
+			t.Errorf("%v: request timeout %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	a, _, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--timeout", "0s", "192.30.252.42"}); code != 2 {
+		t.Fatalf("expected usage error for --timeout 0, got %d: %s", code, stderr)
+	}
+}
+
+func TestSourceConfigFile(t *testing.T) {
+	cacheDir := t.TempDir()
+	config := writeTestFile(t, "cidr.conf", "# shared settings\ntimeout = 30s\nlabels = hooks\ncache-dir = "+cacheDir+"\nendpoint = https://ghes.example/api/v3/meta\ntoken = s3cret\n")
+
+	var src sourceOptions
+	fs := newFlagSet("test", io.Discard)
+	src.addFlags(fs)
+	if err := fs.Parse([]string{"--config", config, "--timeout", "5s"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.parsed(fs); err != nil {
+		t.Fatal(err)
+	}
+	if src.timeout != 5*time.Second {
+		t.Errorf("--timeout should override the config file, got %s", src.timeout)
+	}
+	if src.cacheDir != cacheDir || !src.cacheDirSet {
+		t.Errorf("expected cache dir %s from the config file, got %q (set %v)", cacheDir, src.cacheDir, src.cacheDirSet)
+	}
+	if src.endpoint != "https://ghes.example/api/v3/meta" || src.token != "s3cret" || strings.Join(src.labelFilter(), ",") != "hooks" {
+		t.Errorf("unexpected settings from the config file: endpoint %q, token %q, labels %v", src.endpoint, src.token, src.labelFilter())
+	}
+
+	// The settings reach the request: the endpoint is fetched with the token and
+	// only the configured labels are kept.
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testMeta))
+	}))
+	defer srv.Close()
+	config = writeTestFile(t, "cidr.json", `{"endpoint": "`+srv.URL+`", "cache-dir": "", "token": "s3cret", "labels": ["hooks"]}`)
+	a, stdout, stderr := newTestApp("")
+	a.run(context.Background(), []string{"--config", config, "--format", "json", "--fields", "input,owned", "192.30.252.42", "140.82.112.5"})
+	if auth != "Bearer s3cret" {
+		t.Errorf("expected the configured token, got Authorization %q (%s)", auth, stderr)
+	}
+	if want := `{"input":"192.30.252.42","owned":true}` + "\n" + `{"input":"140.82.112.5","owned":false}` + "\n"; stdout.String() != want {
+		t.Errorf("expected only hooks ranges, got %q", stdout)
+	}
+
+	config = writeTestFile(t, "typo.conf", "timout = 30s\n")
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--config", config, "8.8.8.8"}); code != 2 || !strings.Contains(stderr.String(), `unknown setting(s) timout`) {
+		t.Fatalf("expected usage error for an unknown key, got %d: %s", code, stderr)
+	}
+}
+
+func TestRunDiffCheckUpdate(t *testing.T) {
+	current := `"v1"`
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Header.Get("If-None-Match") == current {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", current)
+		_, _ = w.Write([]byte(testMeta))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "meta.etag"), []byte(`"v1"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"diff", "--check-update", "--endpoint", srv.URL, "--cache-dir", dir}
+
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), args); code != 0 || stdout.String() != "up to date\n" {
+		t.Fatalf("expected up to date on 304, got %d: %q %s", code, stdout, stderr)
+	}
+
+	current = `"v2"`
+	a, stdout, stderr = newTestApp("")
+	if code := a.run(context.Background(), args); code != exitUpdateAvailable || stdout.String() != "update available\n" {
+		t.Fatalf("expected update available on 200, got %d: %q %s", code, stdout, stderr)
+	}
+
+	if etag, _ := os.ReadFile(filepath.Join(dir, "meta.etag")); string(etag) != `"v1"` {
+		t.Errorf("--check-update should leave the cache alone, ETag is now %s", etag)
+	}
+	if strings.Join(methods, " ") != "HEAD HEAD" {
+		t.Errorf("expected two HEAD requests, got %v", methods)
+	}
+}
+
+func TestRunDiffVerifyCache(t *testing.T) {
+	live := testMeta
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Errorf("--verify-cache sent If-None-Match %q", r.Header.Get("If-None-Match"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v2"`)
+		_, _ = w.Write([]byte(live))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cached := `{"hooks": ["192.30.252.0/22", "2001:db8:1::/48"], "web": ["140.82.112.0/20"], "api": ["192.30.252.0/24"], "pages": ["185.199.108.0/22"], "git": ["192.0.2.0/24"]}`
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte(cached), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta.etag"), []byte(`"v1"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"diff", "--verify-cache", "--endpoint", srv.URL, "--cache-dir", dir}
+
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), args); code != exitUpdateAvailable {
+		t.Fatalf("expected exit %d on divergence, got %d: %s", exitUpdateAvailable, code, stderr)
+	}
+	if want := "cache differs from the live meta data: 0 added, 1 removed\n  - git 192.0.2.0/24\n"; stdout.String() != want {
+		t.Fatalf("got %q, want %q", stdout, want)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(dir, "meta.json")); string(raw) != cached {
+		t.Errorf("--verify-cache should leave the cache alone, meta.json is now %s", raw)
+	}
+
+	live = cached
+	a, stdout, stderr = newTestApp("")
+	if code := a.run(context.Background(), args); code != 0 || stdout.String() != "cache matches the live meta data\n" {
+		t.Fatalf("expected a match, got %d: %q %s", code, stdout, stderr)
+	}
+}
+
+func TestRunPreferCacheMakesNoRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("--prefer-cache with a fresh cache sent a %s request", r.Method)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte(testMeta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--endpoint", srv.URL, "--cache-dir", dir, "--prefer-cache", "192.30.252.44"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "192.30.252.44 -> owned by GitHub (api, hooks)") {
+		t.Fatalf("expected the cached ranges to be used, got %q", stdout)
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--cache-ttl", "5m", "192.30.252.44"}); code != 2 || !strings.Contains(stderr.String(), "--cache-ttl requires --prefer-cache") {
+		t.Fatalf("expected a usage error for --cache-ttl alone, got %d: %s", code, stderr)
+	}
+}
+
+func TestRunTimingGoesToStderr(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--timing", "192.30.252.0/23", "8.8.8.8"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	for _, want := range []string{"timing: fetch took ", "(source: file)", "timing: evaluated 513 addresses in "} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("expected %q on stderr, got %q", want, stderr)
+		}
+	}
+	if strings.Contains(stdout.String(), "timing:") {
+		t.Fatalf("timing leaked to stdout: %q", stdout)
+	}
+}
+
+func TestRunArchiveDirPicksSnapshotOnOrBeforeDate(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"meta-2024-01-01.json": `{"web": ["140.82.112.0/20"]}`,
+		"meta-2024-02-01.json": `{"api": ["140.82.112.0/20"]}`,
+		"meta-2024-03-01.json": `{"hooks": ["140.82.112.0/20"]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--archive-dir", dir, "--on", "2024-02-20", "140.82.112.5"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "meta-2024-02-01.json") || !strings.Contains(stdout.String(), "140.82.112.5 -> owned by GitHub (api)") {
+		t.Fatalf("expected the 2024-02-01 snapshot to be used, got %q", stdout)
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--archive-dir", dir, "--on", "2023-12-31", "140.82.112.5"}); code != 1 {
+		t.Fatalf("expected exit 1 for a date before every snapshot, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "no snapshot in "+dir+" on or before 2023-12-31") {
+		t.Fatalf("expected a clear error, got %q", stderr)
+	}
+}
+
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	names, ok := f[addr]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return names, nil
+}
+
+func TestRunPTRPrintsHostnamesAlongsideLabels(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+	a.resolver = fakeResolver{"140.82.112.3": {"lb-140-82-112-3-iad.github.com."}}
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--ptr", "140.82.112.3", "8.8.8.8"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "140.82.112.3 -> owned by GitHub (web) [PTR: lb-140-82-112-3-iad.github.com]") {
+		t.Fatalf("expected PTR alongside labels, got %q", out)
+	}
+	if !strings.Contains(out, "8.8.8.8 -> not owned by GitHub (based on snapshot "+snapshot+") [PTR: none]") {
+		t.Fatalf("expected NXDOMAIN to be reported as no PTR, got %q", out)
+	}
+}
+
+func TestRunJSONFieldsSelection(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--format", "json", "--fields", "owned,labels", "192.30.252.44"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not a single JSON object: %v (%q)", err, stdout)
+	}
+	if len(got) != 2 || got["owned"] != true {
+		t.Fatalf("expected only owned and labels, got %v", got)
+	}
+	for _, absent := range []string{"input", "address", "prefixes"} {
+		if _, ok := got[absent]; ok {
+			t.Fatalf("field %q should be absent, got %v", absent, got)
+		}
+	}
+	if labels, _ := got["labels"].([]any); len(labels) != 2 || labels[0] != "api" || labels[1] != "hooks" {
+		t.Fatalf("expected labels [api hooks], got %v", got["labels"])
+	}
+}
+
+func TestRunJSONFieldsRejectsUnknownField(t *testing.T) {
+	a, _, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--format", "json", "--fields", "owned,bogus"}); code != 2 {
+		t.Fatalf("expected exit 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown field "bogus"`) {
+		t.Fatalf("expected unknown field error, got %q", stderr)
+	}
+}
+
+func TestRunLabelOverlap(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--label-overlap", "api", "hooks"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "api and hooks share 256 addresses") {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+}
+
+func TestRunLabelDiff(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--label-diff", "api", "hooks"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	want := "only in api:\n  (none)\nonly in hooks:\n  192.30.253.0/24\n  192.30.254.0/23\n  2001:db8:1::/48\nin both:\n  192.30.252.0/24\n"
+	if !strings.HasSuffix(stdout.String(), want) {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+}
+
+func TestRunHistogram(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--histogram"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	bar := strings.Repeat("#", 20)
+	want := "IPv4 prefix lengths:\n" +
+		"  /20      1 " + bar + "\n" +
+		"  /22      2 " + bar + bar + "\n" +
+		"  /24      1 " + bar + "\n" +
+		"IPv6 prefix lengths:\n" +
+		"  /48      1 " + bar + bar + "\n"
+	if !strings.HasSuffix(stdout.String(), want) {
+		t.Fatalf("got %q, want it to end with %q", stdout, want)
+	}
+}
+
+func TestRunAuditAllowlistFromURL(t *testing.T) {
+	allowlist := "# edge firewall\n192.30.252.0/23\n140.82.112.0/20, 185.199.108.0/22\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, allowlist)
+	}))
+	defer srv.Close()
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+
+	a, stdout, stderr := newTestApp("")
+	a.client = srv.Client()
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--audit-allowlist", srv.URL}); code != 1 {
+		t.Fatalf("expected exit 1 with uncovered ranges, got %d: %s", code, stderr)
+	}
+	want := "hooks: 192.30.254.0/23\nhooks: 2001:db8:1::/48\n2 GitHub blocks are not covered by " + srv.URL + "\n"
+	if !strings.HasSuffix(stdout.String(), want) {
+		t.Fatalf("got %q, want it to end with %q", stdout, want)
+	}
+
+	allowlist += "192.30.254.0/23\n2001:db8:1::/48\n"
+	a, stdout, stderr = newTestApp("")
+	a.client = srv.Client()
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--audit-allowlist", srv.URL}); code != 0 {
+		t.Fatalf("expected exit 0 for a complete allowlist, got %d: %s %s", code, stdout, stderr)
+	}
+
+	allowlist = "<html>sign in</html>"
+	a, _, stderr = newTestApp("")
+	a.client = srv.Client()
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--audit-allowlist", srv.URL}); code != 1 || !strings.Contains(stderr.String(), "not CIDRs or IP addresses") {
+		t.Fatalf("expected invalid content to be refused, got %d: %s", code, stderr)
+	}
+}
+
+func TestRunAuditAllowlistUsesProxy(t *testing.T) {
+	var gotURL, gotAgent, gotAuth string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL, gotAgent, gotAuth = r.URL.String(), r.Header.Get("User-Agent"), r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "192.30.252.0/22\n140.82.112.0/20\n185.199.108.0/22\n2001:db8:1::/48\n")
+	}))
+	defer proxy.Close()
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+
+	// The configured token belongs to the meta endpoint and is not sent elsewhere.
+	config := writeTestFile(t, "cidr.conf", "token = s3cret\n")
+
+	a, stdout, stderr := newTestApp("")
+	args := []string{"stats", "--as-of", snapshot, "--config", config, "--proxy", proxy.URL, "--audit-allowlist", "http://allowlist.example/ranges.txt"}
+	if code := a.run(context.Background(), args); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s %s", code, stdout, stderr)
+	}
+	if gotURL != "http://allowlist.example/ranges.txt" || gotAgent != "cidr-calculator-github/1.0" {
+		t.Fatalf("allowlist not fetched through --proxy: url %q, user agent %q", gotURL, gotAgent)
+	}
+	if gotAuth != "" {
+		t.Fatalf("the token was sent to the allowlist host: %q", gotAuth)
+	}
+}
+
+func TestRunDumpRaw(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	want := loadTestMeta(t).Entries()
+
+	for _, extra := range [][]string{nil, {"--pretty"}} {
+		a, stdout, stderr := newTestApp("")
+		args := append([]string{"--as-of", snapshot, "--dump-raw"}, extra...)
+		if code := a.run(context.Background(), args); code != 0 {
+			t.Fatalf("%v: run exited %d: %s", extra, code, stderr)
+		}
+		if extra == nil && strings.TrimSpace(stdout.String()) != strings.TrimSpace(testMeta) {
+			t.Fatalf("expected the document byte for byte, got %q", stdout)
+		}
+		meta, err := githubmeta.Parse(stdout)
+		if err != nil {
+			t.Fatalf("%v: dumped JSON does not parse: %v", extra, err)
+		}
+		if got := meta.Entries(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%v: dumped JSON parses to %v, want %v", extra, got, want)
+		}
+	}
+
+	a, _, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--pretty"}); code != 2 || !strings.Contains(stderr.String(), "--pretty requires --dump-raw") {
+		t.Fatalf("expected a usage error for --pretty alone, got %d: %s", code, stderr)
+	}
+
+	// Labels from --config filter the document just like --labels.
+	config := writeTestFile(t, "cidr.conf", "labels = hooks\n")
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--config", config, "--dump-raw"}); code != 2 || !strings.Contains(stderr.String(), "--dump-raw cannot be combined with --labels") {
+		t.Fatalf("expected a usage error for configured labels, got %d: %s", code, stderr)
+	}
+}
+
+func TestRunInputJSON(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp(`["192.30.252.42", "140.82.112.0/24", "garbage"]`)
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--input-json", "-"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	var results []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("output is not a JSON array: %v\n%s", err, stdout)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d: %s", len(results), stdout)
+	}
+	if results[0]["input"] != "192.30.252.42" || results[0]["owned"] != true {
+		t.Errorf("unexpected address result %v", results[0])
+	}
+	if results[1]["prefix"] != "140.82.112.0/24" || results[1]["owned_count"] != float64(256) {
+		t.Errorf("unexpected CIDR result %v", results[1])
+	}
+	if results[2]["input"] != "garbage" || results[2]["error"] == nil {
+		t.Errorf("unexpected invalid result %v", results[2])
+	}
+
+	// An element that is not a string is answered on its own.
+	a, stdout, stderr = newTestApp(`[42, "8.8.8.8"]`)
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--input-json", "-"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	results = nil
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil || len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v: %s", err, stdout)
+	}
+	if results[0]["input"] != "42" || results[0]["error"] != "not a JSON string" || results[1]["owned"] != false {
+		t.Fatalf("unexpected results %v", results)
+	}
+
+	// Options that would add or hide rows are refused, so every input has exactly
+	// one element, whatever the options.
+	a, stdout, stderr = newTestApp(`["192.30.252.0/30", "8.8.8.8", "192.30.252.42"]`)
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--input-json", "-", "--status", "--fields", "input,status,owned"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	results = nil
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil || len(results) != 3 {
+		t.Fatalf("expected one element per input, got %v: %s", err, stdout)
+	}
+	if results[0]["status"] != "FULL" || results[1]["input"] != "8.8.8.8" || results[2]["owned"] != true {
+		t.Fatalf("unexpected results %v", results)
+	}
+	for _, flag := range []string{"--per-address", "--expand-labels", "--only-misses", "--only-matches"} {
+		a, _, stderr = newTestApp(`["192.30.252.0/30"]`)
+		if code := a.run(context.Background(), []string{"--as-of", snapshot, "--input-json", "-", flag}); code != 2 || !strings.Contains(stderr.String(), "--input-json") {
+			t.Fatalf("%s: expected a usage error, got %d: %s", flag, code, stderr)
+		}
+	}
+
+	a, _, stderr = newTestApp(`{"addresses": []}`)
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--input-json", "-"}); code != 1 || !strings.Contains(stderr.String(), "expects a JSON array") {
+		t.Fatalf("expected an error for a non-array document, got %d: %s", code, stderr)
+	}
+}
+
+func loadTestMeta(t *testing.T) *githubmeta.MetaData {
+	t.Helper()
+	meta, err := githubmeta.LoadFromFile(writeTestFile(t, "meta.json", testMeta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meta
+}
+
+func newTestChecker(t *testing.T) (*checker, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	return &checker{
+		meta:       loadTestMeta(t),
+		out:        &out,
+		disclaimer: "based on current meta data",
+		format:     "text",
+		limit:      defaultCIDRLimit,
+	}, &out
+}
+
+func TestRunComplement(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--complement", "192.30.250.0/23"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.HasSuffix(stdout.String(), "\n192.30.250.0/23\n") {
+		t.Fatalf("expected the uncovered block, got %q", stdout)
+	}
+
+	a, stdout, _ = newTestApp("")
+	a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--complement", "192.30.253.0/24"})
+	if !strings.Contains(stdout.String(), "192.30.253.0/24 is fully covered by GitHub ranges") {
+		t.Fatalf("expected fully covered message, got %q", stdout)
+	}
+}
+
+func TestRunStatsSupernets(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--supernets", "16", "--supernets6", "32"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.HasSuffix(stdout.String(), "\n140.82.0.0/16\n185.199.0.0/16\n192.30.0.0/16\n2001:db8::/32\n") {
+		t.Fatalf("unexpected supernets %q", stdout)
+	}
+
+	a, _, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--supernets", "33"}); code != 2 {
+		t.Fatalf("expected usage error for /33, got exit %d", code)
+	}
+}
+
+func TestRunStatsPerimeter(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--perimeter"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	want := "\n140.82.111.255\n140.82.128.0\n185.199.107.255\n185.199.112.0\n192.30.251.255\n192.31.0.0\n2001:db8:0:ffff:ffff:ffff:ffff:ffff\n2001:db8:2::\n"
+	if !strings.HasSuffix(stdout.String(), want) {
+		t.Fatalf("unexpected perimeter %q", stdout)
+	}
+}
+
+func TestRunEmitMatchesLabelsRegardlessOfCase(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", `{"Pages": ["185.199.108.0/22"], "web": ["140.82.112.0/20"]}`)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--format", "json", "pages"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	var got map[string][]string
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("decode %q: %v", stdout, err)
+	}
+	if want := map[string][]string{"Pages": {"185.199.108.0/22"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("emitted %v, want %v", got, want)
+	}
+}
+
+func TestRunStatsSummarisesLabels(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.HasSuffix(stdout.String(), "\napi: 1 prefixes\nhooks: 2 prefixes\npages: 1 prefixes\nweb: 1 prefixes\n") {
+		t.Fatalf("unexpected summary %q", stdout)
+	}
+}
+
+func TestRunEmitCoalescesSelectedLabels(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "api", "hooks"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if got, want := stdout.String(), "192.30.252.0/22\n2001:db8:1::/48\n"; got != want {
+		t.Fatalf("emit printed %q, want %q", got, want)
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "nope"}); code != 1 {
+		t.Fatalf("expected exit 1 for an unknown label, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown label "nope"`) {
+		t.Fatalf("expected unknown label error, got %q", stderr)
+	}
+}
+
+func TestRunOnlyMissesHidesOwnedButTalliesAll(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	input := writeTestFile(t, "ips.txt", "192.30.252.44\n8.8.8.8\n140.82.112.5\n1.1.1.1\nnot-an-ip\n")
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--only-misses", "--input", input}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	for _, want := range []string{"8.8.8.8 -> not owned", "1.1.1.1 -> not owned", "not-an-ip -> invalid", "Summary: 5 checked: 2 owned, 2 not owned, 1 invalid"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "-> owned by GitHub") {
+		t.Errorf("owned addresses should be hidden, got %q", out)
+	}
+
+	// A CIDR is one input, whether or not its rows are shown or cut off.
+	a, stdout, stderr = newTestApp("")
+	args := []string{"--as-of", snapshot, "--only-misses", "--per-address", "--max-results", "1", "192.30.252.0/30", "8.8.8.0/30", "10.0.0.0/8", "1.1.1.1"}
+	if code := a.run(context.Background(), args); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out = stdout.String()
+	for _, want := range []string{"8.8.8.0 -> not owned", "… (truncated, 3 more)", "8.8.8.0/30 -> 0 of 4 addresses", "10.0.0.0/8 -> CIDR too large", "Summary: 4 checked: 1 owned, 2 not owned, 1 invalid"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"--only-misses", "--only-matches", "8.8.8.8"}); code != 2 {
+		t.Fatalf("expected exit 2 for conflicting filters, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "mutually exclusive") {
+		t.Fatalf("expected a conflict error, got %q", stderr)
+	}
+}
+
+func TestRunEmitJSONLabelMap(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--format", "json"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	var got map[string][]string
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not a JSON object: %v (%q)", err, stdout)
+	}
+	want := map[string][]string{
+		"api":   {"192.30.252.0/24"},
+		"hooks": {"192.30.252.0/22", "2001:db8:1::/48"},
+		"pages": {"185.199.108.0/22"},
+		"web":   {"140.82.112.0/20"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("emitted %v, want %v", got, want)
+	}
+}
+
+func TestRunEmitMetaKeepsNonCIDRFields(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", `{"hooks": ["192.30.252.0/22"], "web": ["140.82.112.0/20"], "ssh_keys": ["ssh-ed25519 AAAA"]}`)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--format", "meta", "web"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	var got map[string][]string
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not a JSON object: %v (%q)", err, stdout)
+	}
+	want := map[string][]string{
+		"web":      {"140.82.112.0/20"},
+		"ssh_keys": {"ssh-ed25519 AAAA"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("emitted %v, want %v", got, want)
+	}
+}
+
+func TestRunEmitJSONSplitFamily(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--format", "json", "--split-family", "hooks", "web"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	var got map[string]map[string][]string
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("stdout is not a JSON object: %v (%q)", err, stdout)
+	}
+	want := map[string]map[string][]string{
+		"ipv4": {"hooks": {"192.30.252.0/22"}, "web": {"140.82.112.0/20"}},
+		"ipv6": {"hooks": {"2001:db8:1::/48"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("emitted %v, want %v", got, want)
+	}
+}
+
+func TestRunSampleSameSeedIsReproducible(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	run := func(args ...string) (string, string) {
+		a, stdout, stderr := newTestApp("")
+		args = append(append([]string{"check", "--as-of", snapshot, "--sample", "300"}, args...), "192.30.0.0/16", "2001:db8::/32")
+		if code := a.run(context.Background(), args); code != 0 {
+			t.Fatalf("run exited %d: %s", code, stderr)
+		}
+		return stdout.String(), stderr.String()
+	}
+
+	first, stderr := run("--seed", "42")
+	if second, _ := run("--seed", "42"); first != second {
+		t.Fatalf("same seed gave different output:\n%s\n%s", first, second)
+	}
+	if strings.Contains(stderr, "sample:") {
+		t.Fatalf("an explicit seed should not be echoed, got %q", stderr)
+	}
+
+	// Without --seed the time-based seed is printed, and passing it back
+	// reproduces the run.
+	random, stderr := run()
+	seed, ok := strings.CutPrefix(strings.TrimSpace(stderr), "sample: --seed ")
+	if !ok {
+		t.Fatalf("expected the seed on stderr, got %q", stderr)
+	}
+	if again, _ := run("--seed", seed); again != random {
+		t.Fatalf("the printed seed did not reproduce the run:\n%s\n%s", random, again)
+	}
+}
+
+func TestRunCheckInterfaces(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+	a.interfaceAddrs = func() ([]interfaceAddr, error) {
+		return []interfaceAddr{
+			{"lo", netip.MustParseAddr("127.0.0.1")},
+			{"eth0", netip.MustParseAddr("10.1.0.4")},
+			{"eth0", netip.MustParseAddr("fe80::1")},
+			{"eth1", netip.MustParseAddr("192.30.252.44")},
+			{"eth1", netip.MustParseAddr("2001:db8:1::5")},
+		}, nil
+	}
+
+	if code := a.run(context.Background(), []string{"check", "--as-of", snapshot, "--check-interfaces"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"eth1: 192.30.252.44 -> owned by GitHub (api, hooks)\n",
+		"eth1: 2001:db8:1::5 -> owned by GitHub (hooks)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "10.1.0.4") || strings.Contains(out, "None of") {
+		t.Fatalf("only owned addresses should be reported, got %q", out)
+	}
+}
+
+func TestRunExportIndexThenIndex(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	index := filepath.Join(t.TempDir(), "github.idx")
+	a, _, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"stats", "--as-of", snapshot, "--export-index", index}); code != 0 {
+		t.Fatalf("export exited %d: %s", code, stderr)
+	}
+
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"check", "--index", index, "192.30.252.44", "8.8.8.8"}); code != 0 {
+		t.Fatalf("check exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"192.30.252.44 -> owned by GitHub (api, hooks)\n",
+		"8.8.8.8 -> not owned by GitHub (based on index " + index + ")\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestRunRunnersProfile(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", `{"hooks": ["192.30.252.0/22"], "actions": ["4.148.0.0/16"]}`)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"check", "--as-of", snapshot, "--runners", "4.148.1.1", "192.30.252.44"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "4.148.1.1 -> owned by GitHub (actions)\n") {
+		t.Fatalf("expected the actions address to be selected, got %q", out)
+	}
+	if !strings.Contains(out, "192.30.252.44 -> not owned by GitHub") {
+		t.Fatalf("expected the hooks address to be left out, got %q", out)
+	}
+
+	a, _, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"check", "--as-of", snapshot, "--runners", "--labels", "hooks", "4.148.1.1"}); code != 2 {
+		t.Fatalf("--runners with --labels exited %d, want 2", code)
+	}
+}
+
+func TestRunOnlyFamily(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--only-family", "ipv4"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if out := stdout.String(); strings.Contains(out, ":") || !strings.Contains(out, "192.30.252.0/22") {
+		t.Fatalf("expected only IPv4 prefixes, got %q", out)
+	}
+
+	a, _, _ = newTestApp("")
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--only-family", "ip4"}); code != 2 {
+		t.Fatalf("an unknown family exited %d, want 2", code)
+	}
+}
+
+func TestRunAnnotateTabDelimited(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	input := writeTestFile(t, "access.log", "ts\tclient\tpath\n"+
+		"1\t192.30.252.44\t/hook\n"+
+		"2\t8.8.8.8\t/\n"+
+		"\n"+
+		"3\t[2001:db8:1::7]:443\t/v6\n"+
+		"4\n")
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"check", "--as-of", snapshot, "--input", input, "--annotate", "--column", "2"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	want := "ts\tclient\tpath\t-\n" +
+		"1\t192.30.252.44\t/hook\tapi,hooks\n" +
+		"2\t8.8.8.8\t/\t-\n" +
+		"\n" +
+		"3\t[2001:db8:1::7]:443\t/v6\thooks\n" +
+		"4\t-\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	a, stdout, stderr = newTestApp("203.0.113.9,140.82.112.1\n")
+	if code := a.run(context.Background(), []string{"check", "--as-of", snapshot, "--annotate", "--column", "2", "--delimiter", ","}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if got := stdout.String(); got != "203.0.113.9,140.82.112.1,web\n" {
+		t.Fatalf("comma-delimited stdin: got %q", got)
+	}
+}
+
+func TestRunEmitIPSet(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--format", "ipset", "--set-name", "gh", "--hashsize", "64"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	want := "create gh hash:net family inet hashsize 64\n" +
+		"add gh 140.82.112.0/20\n" +
+		"add gh 185.199.108.0/22\n" +
+		"add gh 192.30.252.0/22\n" +
+		"create gh6 hash:net family inet6 hashsize 64\n" +
+		"add gh6 2001:db8:1::/48\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("emitted %q, want %q", got, want)
+	}
+
+	a, _, stderr = newTestApp("")
+	if code := a.run(context.Background(), []string{"emit", "--as-of", snapshot, "--set-name", "gh"}); code != 2 {
+		t.Fatalf("--set-name without --format ipset exited %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "require --format ipset") {
+		t.Fatalf("expected a usage error, got %q", stderr)
+	}
+}
+
+func TestRunPipedStdinSkipsPrompts(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("192.30.252.44\n\n8.8.8.8\n")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	if strings.HasPrefix(out, "> ") || strings.Contains(out, "\n> ") || strings.Contains(out, "Enter an IP address") {
+		t.Fatalf("piped input should not prompt, got %q", out)
+	}
+	if !strings.Contains(out, "192.30.252.44 -> owned by GitHub (api, hooks)") || !strings.Contains(out, "8.8.8.8 -> not owned by GitHub") {
+		t.Fatalf("expected both piped addresses to be checked, got %q", out)
+	}
+}
+
+func TestRunREPLCompletesLabels(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("? h\n?\n")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "\nhooks\napi\nhooks\npages\nweb\n") {
+		t.Fatalf("expected hooks, then every label, got %q", out)
+	}
+	if strings.Contains(out, "invalid") {
+		t.Fatalf("? was evaluated as an address: %q", out)
+	}
+}
+
+func TestRunJSONOutAlongsideText(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	jsonOut := filepath.Join(t.TempDir(), "results.jsonl")
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--json-out", jsonOut, "192.30.252.44", "8.8.8.8", "192.30.252.0/23"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	out := stdout.String()
+	for _, want := range []string{"192.30.252.44 -> owned by GitHub (api, hooks)", "8.8.8.8 -> not owned by GitHub", "192.30.252.0/23 -> 512 of 512 addresses owned by GitHub"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q on stdout, got %q", want, out)
+		}
+	}
+	if strings.Contains(out, "{") {
+		t.Errorf("stdout should stay human-readable, got %q", out)
+	}
+
+	data, err := os.ReadFile(jsonOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 JSON lines, got %q", data)
+	}
+	var owned, missed addrResult
+	var cidr cidrResult
+	for i, v := range []any{&owned, &missed, &cidr} {
+		if err := json.Unmarshal([]byte(lines[i]), v); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i, err)
+		}
+	}
+	if owned.Input != "192.30.252.44" || !owned.Owned || strings.Join(owned.Labels, ",") != "api,hooks" {
+		t.Errorf("unexpected owned result %+v", owned)
+	}
+	if missed.Input != "8.8.8.8" || missed.Owned {
+		t.Errorf("unexpected missed result %+v", missed)
+	}
+	if cidr.Prefix != "192.30.252.0/23" || cidr.OwnedCount != 512 {
+		t.Errorf("unexpected CIDR result %+v", cidr)
+	}
+}
+
+func TestRunREPLReportsOverlongLine(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	long := strings.Repeat("1", maxInputLine+1)
+	a, _, stderr := newTestApp("192.30.252.44\n" + long + "\n8.8.8.8\n")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot}); code != 1 {
+		t.Fatalf("run exited %d, want 1: %s", code, stderr)
+	}
+	if !strings.Contains(stderr.String(), "input line too long") {
+		t.Fatalf("expected a clear error for the long line, got %q", stderr)
+	}
+}
+
+func TestRunREPLAcceptsLongLine(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	// Far past bufio's default 64 KiB token limit.
+	line := strings.TrimSuffix(strings.Repeat("8.8.8.8,", 10000), ",") + ",192.30.252.44"
+	a, stdout, stderr := newTestApp(line + "\n")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout.String(), "192.30.252.44 -> owned by GitHub (api, hooks)") {
+		t.Fatalf("expected the last input on the long line to be checked, got %d bytes of output", stdout.Len())
+	}
+}
+
+func TestCheckModesCombinations(t *testing.T) {
+	set := checkOptionsSet(checkOptions{}, false, false)
+	for _, mode := range checkModes {
+		for _, name := range append([]string{mode.name}, mode.conflicts...) {
+			if _, ok := set[name]; !ok {
+				t.Errorf("checkModes names %s, which checkOptionsSet does not know", name)
+			}
+		}
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--count", "--format", "json", "8.8.8.8"}, "--count cannot be combined with --format json"},
+		{[]string{"--cover", "--labels-only", "8.8.8.8"}, "--cover cannot be combined with --labels-only"},
+		{[]string{"--aggregate", "--state", "s.json", "192.30.252.0/24"}, "--state cannot be combined with --aggregate"},
+		{[]string{"--group-by-family"}, "--group-by-family requires addresses or --input"},
+		{[]string{"--check-interfaces", "8.8.8.8"}, "--check-interfaces takes no addresses or --input"},
+		{[]string{"--annotate", "--column", "1", "8.8.8.8"}, "--annotate reads --input or stdin and takes no addresses"},
+		{[]string{"--dump-raw", "--runners"}, "--dump-raw cannot be combined with --runners"},
+		{[]string{"--compare", "--input", "ips.txt", "8.8.8.8", "1.1.1.1"}, "--compare cannot be combined with --input"},
+		{[]string{"--state", "s.json", "8.8.8.8"}, "--state expects exactly one CIDR argument"},
+	}
+	for _, tc := range cases {
+		var stderr bytes.Buffer
+		if _, _, err := parseCheckOptions(tc.args, &stderr); err == nil || !strings.Contains(stderr.String(), tc.want) {
+			t.Errorf("%v: got %v, want a usage error containing %q; stderr %q", tc.args, err, tc.want, stderr.String())
+		}
+	}
+	if _, _, err := parseCheckOptions([]string{"--count", "--bitmask-exit", "--input", "ips.txt"}, io.Discard); err != nil {
+		t.Errorf("compatible options refused: %v", err)
+	}
+}
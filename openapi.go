@@ -0,0 +1,164 @@
+package main
+
+import (
+	"math/big"
+	"reflect"
+)
+
+// openAPISpec is the subset of an OpenAPI 3.0 document needed to describe the serve
+// endpoints. It is assembled by hand rather than pulled in from a library.
+type openAPISpec struct {
+	OpenAPI string                 `json:"openapi"`
+	Info    openAPIInfo            `json:"info"`
+	Paths   map[string]openAPIPath `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIPath struct {
+	Get  *openAPIOperation `json:"get,omitempty"`
+	Post *openAPIOperation `json:"post,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                    `json:"required"`
+	Content  map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Required    bool        `json:"required"`
+	Description string      `json:"description"`
+	Schema      *jsonSchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openAPIMedia `json:"content,omitempty"`
+}
+
+type openAPIMedia struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+type jsonSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+}
+
+// openAPI describes the serve endpoints. Response schemas are derived from the result
+// structs the handlers encode, so the spec follows them automatically.
+func openAPI() openAPISpec {
+	lookup := jsonResponse("lookup result", reflect.TypeOf(addrResult{}))
+	return openAPISpec{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "cidr-calculator-github", Version: "1.0"},
+		Paths: map[string]openAPIPath{
+			"/lookup": {Get: &openAPIOperation{
+				Summary: "Report which GitHub ranges contain an IP address",
+				Parameters: []openAPIParameter{{
+					Name: "ip", In: "query", Required: true,
+					Description: "IPv4 or IPv6 address to look up",
+					Schema:      &jsonSchema{Type: "string"},
+				}},
+				Responses: map[string]openAPIResponse{
+					"200": lookup,
+					"400": jsonResponse("invalid address; error and reason are set", reflect.TypeOf(addrResult{})),
+					"503": jsonResponse("the meta data has not loaded yet", reflect.TypeOf(healthStatus{})),
+				},
+			}},
+			"/lookup/stream": {Post: &openAPIOperation{
+				Summary: "Look up many addresses and CIDRs, streaming each result as a Server-Sent Event whose id is the input's position",
+				RequestBody: &openAPIRequestBody{
+					Required: true,
+					Content:  map[string]openAPIMedia{"text/plain": {Schema: &jsonSchema{Type: "string"}}},
+				},
+				Responses: map[string]openAPIResponse{
+					"200": {Description: "one event per input; each data field is a lookup result, or a CIDR summary for a CIDR", Content: map[string]openAPIMedia{"text/event-stream": {Schema: &jsonSchema{OneOf: []*jsonSchema{
+						schemaFor(reflect.TypeOf(addrResult{})),
+						schemaFor(reflect.TypeOf(cidrResult{})),
+					}}}}},
+					"503": jsonResponse("the meta data has not loaded yet", reflect.TypeOf(healthStatus{})),
+				},
+			}},
+			"/metrics": {Get: &openAPIOperation{
+				Summary:   "Request counters of this server",
+				Responses: map[string]openAPIResponse{"200": jsonResponse("server metrics", reflect.TypeOf(serverMetrics{}))},
+			}},
+			"/healthz": {Get: &openAPIOperation{
+				Summary:   "Liveness: 200 while the server is running",
+				Responses: map[string]openAPIResponse{"200": jsonResponse("status ok", reflect.TypeOf(healthStatus{}))},
+			}},
+			"/readyz": {Get: &openAPIOperation{
+				Summary: "Readiness: 200 once the meta data has loaded",
+				Responses: map[string]openAPIResponse{
+					"200": jsonResponse("status ready", reflect.TypeOf(healthStatus{})),
+					"503": jsonResponse("status loading; lookups are refused", reflect.TypeOf(healthStatus{})),
+				},
+			}},
+			"/openapi.json": {Get: &openAPIOperation{
+				Summary:   "This document",
+				Responses: map[string]openAPIResponse{"200": {Description: "OpenAPI 3.0 document", Content: map[string]openAPIMedia{"application/json": {Schema: &jsonSchema{Type: "object"}}}}},
+			}},
+		},
+	}
+}
+
+func jsonResponse(description string, t reflect.Type) openAPIResponse {
+	return openAPIResponse{
+		Description: description,
+		Content:     map[string]openAPIMedia{"application/json": {Schema: schemaFor(t)}},
+	}
+}
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+// schemaFor derives a JSON schema from t, using the same json tags as encoding/json.
+func schemaFor(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == bigIntType {
+		return &jsonSchema{Type: "integer"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if name, ok := jsonFieldName(field); ok {
+				s.Properties[name] = schemaFor(field.Type)
+			}
+		}
+		return s
+	default:
+		return &jsonSchema{Type: "object"}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/netip"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// reservedNotice is printed for --warn-reserved inputs instead of a lookup result.
+const reservedNotice = "address is in private/reserved space; GitHub does not use this range"
+
+// reservedKind names the special-purpose scope of addr ("private", "loopback", ...),
+// or returns "" for a global unicast address that may belong to GitHub.
+func reservedKind(addr netip.Addr) string {
+	addr = addr.Unmap()
+	switch {
+	case addr.IsUnspecified():
+		return "unspecified"
+	case addr.IsLoopback():
+		return "loopback"
+	case addr.IsPrivate():
+		return "private"
+	case addr.IsLinkLocalUnicast():
+		return "link-local"
+	case addr.IsMulticast():
+		return "multicast"
+	}
+	return ""
+}
+
+// reservedPrefixKind is reservedKind for a whole prefix: both ends must share the
+// same scope, since every special-purpose block is contiguous.
+func reservedPrefixKind(p netip.Prefix) string {
+	kind := reservedKind(githubmeta.FirstAddr(p))
+	if kind != "" && reservedKind(githubmeta.LastAddr(p)) == kind {
+		return kind
+	}
+	return ""
+}
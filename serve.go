@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+const (
+	defaultServeAddr = "127.0.0.1:8080"
+	// serveLookupCacheSize bounds the memoized lookups of a running server.
+	serveLookupCacheSize = 4096
+	// shutdownTimeout is how long in-flight requests get to finish after Ctrl-C.
+	shutdownTimeout = 5 * time.Second
+)
+
+// runServe implements the serve subcommand: it loads the ranges once and answers
+// lookups over HTTP until interrupted. It listens before loading so that /healthz
+// answers at once and /readyz reports when lookups can be served.
+func (a *app) runServe(ctx context.Context, args []string) int {
+	var src sourceOptions
+	fs := newFlagSet("serve", a.stderr)
+	src.addFlags(fs)
+	addr := fs.String("addr", defaultServeAddr, "address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if fs.NArg() > 0 {
+		return usageExitCode(usageError(fs, "unexpected arguments: %v", fs.Args()))
+	}
+	if err := src.parsed(fs); err != nil {
+		return usageExitCode(err)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+
+	srv := newServer(nil, "")
+	httpSrv := &http.Server{Handler: srv.handler(), ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- httpSrv.Serve(ln)
+	}()
+	shutdown := func() int {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	meta, disclaimer, err := a.loadMeta(ctx, src, a.stderr)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		shutdown()
+		return 1
+	}
+	srv.setMeta(meta.WithLookupCache(serveLookupCacheSize), disclaimer)
+	fmt.Fprintf(a.stderr, "Serving on http://%s (press Ctrl-C to stop)...\n", ln.Addr())
+
+	select {
+	case err := <-errc:
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	case <-ctx.Done():
+	}
+	return shutdown()
+}
+
+// server answers lookups against one loaded copy of the meta data.
+type server struct {
+	// checker is nil until setMeta; lookups are refused until then.
+	checker atomic.Pointer[checker]
+	started time.Time
+
+	mu       sync.Mutex
+	requests int
+	tally    tally
+}
+
+// serverMetrics is the /metrics response.
+type serverMetrics struct {
+	// Requests counts lookups, one per address or CIDR of a /lookup/stream body;
+	// Owned, NotOwned and Invalid break them down.
+	Requests      int     `json:"requests"`
+	Owned         int     `json:"owned"`
+	NotOwned      int     `json:"not_owned"`
+	Invalid       int     `json:"invalid"`
+	Prefixes      int     `json:"prefixes"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+}
+
+// healthStatus is the /healthz and /readyz response.
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+// newServer returns a server for meta, or one that is not ready yet when meta is nil.
+func newServer(meta *githubmeta.MetaData, disclaimer string) *server {
+	s := &server{started: time.Now()}
+	if meta != nil {
+		s.setMeta(meta, disclaimer)
+	}
+	return s
+}
+
+// setMeta makes the server answer lookups from meta.
+func (s *server) setMeta(meta *githubmeta.MetaData, disclaimer string) {
+	s.checker.Store(&checker{meta: meta, disclaimer: disclaimer, limit: defaultCIDRLimit})
+}
+
+// ready reports whether a snapshot with at least one prefix has been loaded.
+func (s *server) ready() bool {
+	c := s.checker.Load()
+	if c == nil {
+		return false
+	}
+	total, _, _ := c.meta.PrefixCount()
+	return total > 0
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lookup", s.handleLookup)
+	mux.HandleFunc("POST /lookup/stream", s.handleLookupStream)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	return mux
+}
+
+// handleLookup reports the labels of the address in the ip query parameter. Invalid
+// input gets a 400 with the same result shape, carrying error and reason; until the
+// meta data has loaded every lookup gets a 503.
+func (s *server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	c := s.checker.Load()
+	if c == nil {
+		writeJSONResponse(w, http.StatusServiceUnavailable, healthStatus{Status: "loading"})
+		return
+	}
+	res := s.lookup(c, strings.TrimSpace(r.URL.Query().Get("ip")))
+	status := http.StatusOK
+	if res.Error != "" {
+		status = http.StatusBadRequest
+	}
+	writeJSONResponse(w, status, res)
+}
+
+// handleLookupStream evaluates the addresses and CIDRs of a body laid out like an
+// --input file and sends each result as a Server-Sent Event as soon as it is known. Results keep
+// the input order, and each event's id is the input's position, counting from 1.
+// A body that cannot be read ends the stream with an "error" event.
+func (s *server) handleLookupStream(w http.ResponseWriter, r *http.Request) {
+	c := s.checker.Load()
+	if c == nil {
+		writeJSONResponse(w, http.StatusServiceUnavailable, healthStatus{Status: "loading"})
+		return
+	}
+	// Without full duplex, HTTP/1 clients could not read results until the whole
+	// body was sent. HTTP/2 always allows it, hence the ignored error.
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	id := 0
+	err := readInputs(r.Body, func(raw string) {
+		id++
+		data, _ := json.Marshal(s.evaluate(c, raw))
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", id, data)
+		_ = rc.Flush()
+	})
+	if err != nil {
+		data, _ := json.Marshal(map[string]string{"error": err.Error()})
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	}
+}
+
+// lookup looks up the address raw with c and counts the result in the metrics.
+func (s *server) lookup(c *checker, raw string) addrResult {
+	var res addrResult
+	if addr, err := netip.ParseAddr(raw); err != nil {
+		res = invalidResult(raw, err)
+	} else {
+		res = c.lookup(raw, addr)
+	}
+
+	s.mu.Lock()
+	s.requests++
+	s.tally.add(res)
+	s.mu.Unlock()
+	return res
+}
+
+// evaluate evaluates the address or CIDR raw like a check argument, returning its
+// addrResult or cidrResult, and counts the result in the metrics.
+func (s *server) evaluate(c *checker, raw string) any {
+	// c is shared by concurrent requests, so each evaluation gets its own copy.
+	local := *c
+	if strings.Contains(raw, "/") {
+		// Every address of a CIDR would pass through the lookup cache and evict the
+		// single addresses it is there for, so CIDRs are evaluated without it.
+		local.meta = c.meta.WithLookupCache(0)
+	}
+	var result any
+	local.collect = func(res any) { result = res }
+	local.evaluateInput(raw)
+
+	s.mu.Lock()
+	s.requests++
+	switch res := result.(type) {
+	case addrResult:
+		s.tally.add(res)
+	case cidrResult:
+		s.tally.addCIDR(res)
+	}
+	s.mu.Unlock()
+	return result
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	m := serverMetrics{
+		Requests: s.requests,
+		Owned:    s.tally.owned,
+		NotOwned: s.tally.notOwned,
+		Invalid:  s.tally.invalid,
+	}
+	s.mu.Unlock()
+	if c := s.checker.Load(); c != nil {
+		m.Prefixes, _, _ = c.meta.PrefixCount()
+	}
+	m.UptimeSeconds = time.Since(s.started).Seconds()
+	writeJSONResponse(w, http.StatusOK, m)
+}
+
+// handleHealthz answers 200 whenever the process is serving, for liveness probes.
+func (s *server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// handleReadyz answers 200 once ranges have been loaded and 503 before, for
+// readiness probes.
+func (s *server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready() {
+		writeJSONResponse(w, http.StatusServiceUnavailable, healthStatus{Status: "loading"})
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, healthStatus{Status: "ready"})
+}
+
+func (s *server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, openAPI())
+}
+
+// writeJSONResponse writes v as the JSON body of a response with the given status.
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveRequest(t *testing.T, s *server, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestServeLookupAndMetrics(t *testing.T) {
+	s := newServer(loadTestMeta(t), "based on current meta data")
+
+	rec := serveRequest(t, s, "/lookup?ip=192.30.252.42")
+	var res addrResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusOK || !res.Owned || strings.Join(res.Labels, ",") != "api,hooks" {
+		t.Fatalf("unexpected lookup response %d %q", rec.Code, rec.Body)
+	}
+
+	if rec := serveRequest(t, s, "/lookup?ip=not-an-ip"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"reason":"not_an_ip"`) {
+		t.Fatalf("unexpected response for an invalid address: %d %q", rec.Code, rec.Body)
+	}
+
+	rec = serveRequest(t, s, "/metrics")
+	var m serverMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body, err)
+	}
+	if m.Requests != 2 || m.Owned != 1 || m.Invalid != 1 || m.Prefixes != 5 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+}
+
+func TestServeReadiness(t *testing.T) {
+	s := newServer(nil, "")
+	if rec := serveRequest(t, s, "/healthz"); rec.Code != http.StatusOK {
+		t.Fatalf("/healthz before load: %d", rec.Code)
+	}
+	if rec := serveRequest(t, s, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz before load: %d, want 503", rec.Code)
+	}
+	if rec := serveRequest(t, s, "/lookup?ip=192.30.252.42"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/lookup before load: %d, want 503", rec.Code)
+	}
+
+	s.setMeta(loadTestMeta(t), "based on current meta data")
+	if rec := serveRequest(t, s, "/readyz"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ready"`) {
+		t.Fatalf("/readyz after load: %d %q", rec.Code, rec.Body)
+	}
+	if rec := serveRequest(t, s, "/lookup?ip=192.30.252.42"); rec.Code != http.StatusOK {
+		t.Fatalf("/lookup after load: %d", rec.Code)
+	}
+}
+
+func TestServeOpenAPIDescribesLookupLabels(t *testing.T) {
+	s := newServer(loadTestMeta(t), "based on current meta data")
+	rec := serveRequest(t, s, "/openapi.json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]struct {
+			Get struct {
+				Responses map[string]struct {
+					Content map[string]struct {
+						Schema jsonSchema `json:"schema"`
+					} `json:"content"`
+				} `json:"responses"`
+			} `json:"get"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if spec.OpenAPI == "" || spec.Paths["/metrics"].Get.Responses["200"].Content == nil {
+		t.Fatalf("incomplete spec %s", rec.Body)
+	}
+	labels := spec.Paths["/lookup"].Get.Responses["200"].Content["application/json"].Schema.Properties["labels"]
+	if labels == nil || labels.Type != "array" || labels.Items == nil || labels.Items.Type != "string" {
+		t.Fatalf("lookup schema does not describe labels as a string array: %s", rec.Body)
+	}
+}
+
+func TestServeLookupStream(t *testing.T) {
+	s := newServer(loadTestMeta(t), "based on current meta data")
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	body := "192.30.252.42\n# comment\n\n8.8.8.8\nnot-an-ip\n2001:db8:1::1\n"
+	resp, err := http.Post(srv.URL+"/lookup/stream", "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	type event struct {
+		id  string
+		res addrResult
+	}
+	var events []event
+	scanner := bufio.NewScanner(resp.Body)
+	var ev event
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.res); err != nil {
+				t.Fatalf("event data %q: %v", line, err)
+			}
+		case line == "":
+			events = append(events, ev)
+			ev = event{}
+		}
+	}
+
+	want := []struct {
+		id, input string
+		owned     bool
+	}{
+		{"1", "192.30.252.42", true},
+		{"2", "8.8.8.8", false},
+		{"3", "not-an-ip", false},
+		{"4", "2001:db8:1::1", true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		if got := events[i]; got.id != w.id || got.res.Input != w.input || got.res.Owned != w.owned {
+			t.Errorf("event %d = id %s %+v, want id %s input %s owned %v", i, got.id, got.res, w.id, w.input, w.owned)
+		}
+	}
+	if events[2].res.Reason != "not_an_ip" {
+		t.Errorf("invalid input should carry a reason, got %+v", events[2].res)
+	}
+
+	rec := serveRequest(t, s, "/metrics")
+	if !strings.Contains(rec.Body.String(), `"requests":4`) {
+		t.Errorf("each streamed lookup should be counted, got %s", rec.Body)
+	}
+}
+
+func TestServeLookupStreamEvaluatesCIDRs(t *testing.T) {
+	s := newServer(loadTestMeta(t), "based on current meta data")
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	body := "192.30.252.0/23\n8.8.8.0/30\n192.30.252.42\n10.0.0.0/8\n"
+	resp, err := http.Post(srv.URL+"/lookup/stream", "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var results []map[string]any
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var res map[string]any
+			if err := json.Unmarshal([]byte(data), &res); err != nil {
+				t.Fatalf("event data %q: %v", data, err)
+			}
+			results = append(results, res)
+		}
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d events, want one per input: %v", len(results), results)
+	}
+	if r := results[0]; r["prefix"] != "192.30.252.0/23" || r["total"] != 512.0 || r["owned_count"] != 512.0 {
+		t.Errorf("unexpected summary for an owned CIDR: %v", r)
+	}
+	if r := results[1]; r["prefix"] != "8.8.8.0/30" || r["owned_count"] != 0.0 {
+		t.Errorf("unexpected summary for an unowned CIDR: %v", r)
+	}
+	if r := results[2]; r["address"] != "192.30.252.42" || r["owned"] != true {
+		t.Errorf("addresses should still get a lookup result, got %v", r)
+	}
+	if r := results[3]; !strings.Contains(fmt.Sprint(r["error"]), "exceeds") || r["suggested_max_prefix"] == nil {
+		t.Errorf("a CIDR over the limit should be refused, got %v", r)
+	}
+
+	rec := serveRequest(t, s, "/metrics")
+	if !strings.Contains(rec.Body.String(), `"requests":4,"owned":2,"not_owned":1,"invalid":1`) {
+		t.Errorf("each streamed CIDR should be counted once, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// sortOrders are the accepted values of --sort-output.
+var sortOrders = []string{"input", "address", "ownership"}
+
+// sortedOutput is the captured output of one batch input under --sort-output or
+// --group-by-family.
+type sortedOutput struct {
+	// addr is the input's address, or its network address for a CIDR; it is the
+	// zero Addr for input that does not parse, which sorts last.
+	addr netip.Addr
+	// rank orders ownership: 0 for owned (any address of a CIDR), 1 for not owned
+	// and 2 for invalid.
+	rank         int
+	out, jsonOut bytes.Buffer
+}
+
+// evaluateBatchInput is evaluateInput for arguments and --input lines. With
+// --sort-output address or ownership, or --group-by-family, the output is captured
+// so flushSorted can write it in order once the batch is complete.
+func (c *checker) evaluateBatchInput(raw string) {
+	if (c.sortOutput == "" || c.sortOutput == "input") && !c.groupByFamily {
+		c.evaluateInput(raw)
+		return
+	}
+
+	item := &sortedOutput{addr: sortAddr(raw)}
+	out, jsonOut, outcomes := c.out, c.jsonOut, c.outcomes
+	c.out, c.outcomes = &item.out, 0
+	if jsonOut != nil {
+		c.jsonOut = &item.jsonOut
+	}
+	c.evaluateInput(raw)
+	switch {
+	case c.outcomes&exitOwned != 0:
+		item.rank = 0
+	case c.outcomes&exitNotOwned != 0:
+		item.rank = 1
+	default:
+		item.rank = 2
+	}
+	c.out, c.jsonOut, c.outcomes = out, jsonOut, outcomes|c.outcomes
+	c.sorted = append(c.sorted, item)
+}
+
+// flushSorted writes the output captured by evaluateBatchInput in --sort-output
+// order, in IPv4, IPv6 and invalid sections with --group-by-family. Inputs that
+// compare equal keep their input order.
+func (c *checker) flushSorted() {
+	sort.SliceStable(c.sorted, func(i, j int) bool {
+		a, b := c.sorted[i], c.sorted[j]
+		switch c.sortOutput {
+		case "ownership":
+			return a.rank < b.rank
+		case "address":
+			if a.addr.IsValid() != b.addr.IsValid() {
+				return a.addr.IsValid()
+			}
+			return a.addr.Less(b.addr)
+		}
+		return false
+	})
+	if !c.groupByFamily {
+		for _, item := range c.sorted {
+			c.writeSorted(item)
+		}
+		c.sorted = nil
+		return
+	}
+
+	sections := []struct {
+		name string
+		in   func(netip.Addr) bool
+	}{
+		{"IPv4", netip.Addr.Is4},
+		{"IPv6", netip.Addr.Is6},
+		{"Invalid", func(addr netip.Addr) bool { return !addr.IsValid() }},
+	}
+	first := true
+	for _, section := range sections {
+		var sub tally
+		for _, item := range c.sorted {
+			if !section.in(item.addr) {
+				continue
+			}
+			if sub == (tally{}) {
+				if !first {
+					fmt.Fprintln(c.out)
+				}
+				fmt.Fprintf(c.out, "%s:\n", section.name)
+				first = false
+			}
+			c.writeSorted(item)
+			switch item.rank {
+			case 0:
+				sub.owned++
+			case 1:
+				sub.notOwned++
+			default:
+				sub.invalid++
+			}
+		}
+		if sub != (tally{}) {
+			fmt.Fprintf(c.out, "%s subtotal: %s\n", section.name, sub)
+		}
+	}
+	c.sorted = nil
+}
+
+// writeSorted writes the captured output of item.
+func (c *checker) writeSorted(item *sortedOutput) {
+	c.out.Write(item.out.Bytes())
+	if c.jsonOut != nil {
+		c.jsonOut.Write(item.jsonOut.Bytes())
+	}
+}
+
+// sortAddr returns the address raw sorts by: the address itself, or the network
+// address of a CIDR.
+func sortAddr(raw string) netip.Addr {
+	if strings.Contains(raw, "/") {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return netip.Addr{}
+		}
+		return prefix.Masked().Addr()
+	}
+	addr, _ := netip.ParseAddr(raw)
+	return addr
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+const cacheDirUsage = "directory for the on-disk cache (empty disables caching; default is the OS cache dir)"
+
+// sourceOptions selects where the meta data comes from. It is shared by every
+// subcommand that needs the ranges.
+type sourceOptions struct {
+	cacheDir    string
+	cacheDirSet bool
+	cacheRO     bool
+	asOf        string
+	// index is a prebuilt --index file to load instead; exportIndex is where to write
+	// one for the loaded ranges.
+	index        string
+	exportIndex  string
+	archiveDir   string
+	on           string
+	expectSHA256 string
+	// timeout bounds each fetch as a whole; readTimeout, when set, is a tighter
+	// deadline for the request and connectTimeout bounds dialing.
+	timeout        time.Duration
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	// config is the --config file; parsed applies it to the flags not set explicitly.
+	config   string
+	endpoint string
+	proxy    string
+	// token is sent as a bearer token; it can only be set in the --config file.
+	token string
+	// labels restricts the loaded ranges to these labels; runners to
+	// githubmeta.RunnerLabels.
+	labels  string
+	runners bool
+	// onlyFamily is "ipv4" or "ipv6" to drop the other family's ranges.
+	onlyFamily string
+	// explainCache traces the fetch's cache decisions to stderr.
+	explainCache bool
+	// preferCache serves a cache younger than cacheTTL without a request.
+	preferCache bool
+	cacheTTL    time.Duration
+}
+
+func (s *sourceOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.cacheDir, "cache-dir", "", cacheDirUsage)
+	fs.BoolVar(&s.cacheRO, "cache-readonly", false, "use an existing cache but never write to it")
+	fs.BoolVar(&s.preferCache, "prefer-cache", false, "use a cache younger than --cache-ttl without contacting GitHub")
+	fs.DurationVar(&s.cacheTTL, "cache-ttl", githubmeta.DefaultCacheTTL, "how long a cached copy counts as fresh for --prefer-cache")
+	fs.StringVar(&s.asOf, "as-of", "", "use an archived meta.json snapshot instead of fetching live data")
+	fs.StringVar(&s.index, "index", "", "load the ranges from a file written by --export-index instead of fetching live data")
+	fs.StringVar(&s.exportIndex, "export-index", "", "write the loaded ranges to this file in a binary form that --index loads quickly")
+	fs.StringVar(&s.expectSHA256, "expect-sha256", "", "refuse fetched or cached meta data whose SHA-256 is not this hex digest")
+	fs.StringVar(&s.archiveDir, "archive-dir", "", "directory of daily meta-YYYY-MM-DD.json snapshots (requires --on)")
+	fs.StringVar(&s.on, "on", "", "use the latest --archive-dir snapshot dated on or before YYYY-MM-DD")
+	fs.DurationVar(&s.timeout, "timeout", fetchTimeout, "overall limit for fetching the meta data")
+	fs.DurationVar(&s.connectTimeout, "connect-timeout", 0, "limit for establishing the connection (default: bounded only by --timeout)")
+	fs.DurationVar(&s.readTimeout, "read-timeout", 0, "limit for the request once sent, capped by --timeout (default: --timeout)")
+	fs.StringVar(&s.config, "config", "", "read default settings from a key=value or JSON file; flags take precedence")
+	fs.StringVar(&s.endpoint, "endpoint", "", "fetch the meta data from this URL instead of https://api.github.com/meta")
+	fs.StringVar(&s.proxy, "proxy", "", "send requests through this proxy URL instead of the one in HTTPS_PROXY")
+	fs.BoolVar(&s.explainCache, "explain-cache", false, "trace to stderr how the cache and the network were used to load the meta data")
+	fs.StringVar(&s.labels, "labels", "", "comma-separated labels to keep; ranges of other labels are ignored")
+	fs.StringVar(&s.onlyFamily, "only-family", "", "load only the ipv4 or ipv6 ranges, saving the memory the others would take")
+	fs.BoolVar(&s.runners, "runners", false, "keep only the ranges of GitHub-hosted runners and codespaces (actions, actions_macos, codespaces)")
+}
+
+// applyConfig loads the --config file and sets every flag of fs it names that was
+// not given on the command line. Settings for flags fs does not have are ignored.
+func (s *sourceOptions) applyConfig(fs *flag.FlagSet) error {
+	settings, err := loadConfig(s.config)
+	if err != nil {
+		return fmt.Errorf("--config: %w", err)
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for key, value := range settings {
+		if key == "token" {
+			s.token = value
+			continue
+		}
+		if explicit[key] || fs.Lookup(key) == nil {
+			continue
+		}
+		if err := fs.Set(key, value); err != nil {
+			return fmt.Errorf("--config: %s: invalid %s %q: %w", s.config, key, value, err)
+		}
+	}
+	return nil
+}
+
+// parsed records which flags were set explicitly and validates their combination;
+// call it after fs.Parse.
+func (s *sourceOptions) parsed(fs *flag.FlagSet) error {
+	// Checked before the --config file applies: --runners overrides its labels, and
+	// a configured cache-ttl only matters to the runs that pass --prefer-cache.
+	if s.runners && s.labels != "" {
+		return usageError(fs, "--runners cannot be combined with --labels")
+	}
+	ttlSet := false
+	fs.Visit(func(f *flag.Flag) { ttlSet = ttlSet || f.Name == "cache-ttl" })
+	if ttlSet && !s.preferCache {
+		return usageError(fs, "--cache-ttl requires --prefer-cache")
+	}
+	if s.config != "" {
+		if err := s.applyConfig(fs); err != nil {
+			return usageError(fs, "%v", err)
+		}
+	}
+	badTimeout := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cache-dir":
+			s.cacheDirSet = true
+		case "timeout":
+			badTimeout = badTimeout || s.timeout <= 0
+		case "connect-timeout", "read-timeout":
+			badTimeout = badTimeout || s.connectTimeout < 0 || s.readTimeout < 0
+		}
+	})
+	if s.preferCache && s.cacheTTL <= 0 {
+		return usageError(fs, "--cache-ttl must be positive")
+	}
+	if (s.archiveDir == "") != (s.on == "") {
+		return usageError(fs, "--archive-dir and --on must be used together")
+	}
+	if s.archiveDir != "" && s.asOf != "" {
+		return usageError(fs, "--archive-dir cannot be combined with --as-of")
+	}
+	if s.onlyFamily != "" && s.onlyFamily != "ipv4" && s.onlyFamily != "ipv6" {
+		return usageError(fs, "invalid --only-family %q (expected ipv4 or ipv6)", s.onlyFamily)
+	}
+	if s.index != "" && (s.asOf != "" || s.archiveDir != "") {
+		return usageError(fs, "--index cannot be combined with --as-of or --archive-dir")
+	}
+	if s.expectSHA256 != "" {
+		if b, err := hex.DecodeString(s.expectSHA256); err != nil || len(b) != sha256.Size {
+			return usageError(fs, "invalid --expect-sha256 %q (expected 64 hex digits)", s.expectSHA256)
+		}
+	}
+	if badTimeout {
+		return usageError(fs, "--timeout must be positive and --connect-timeout and --read-timeout must not be negative")
+	}
+	if s.on != "" {
+		if _, err := time.Parse(time.DateOnly, s.on); err != nil {
+			return usageError(fs, "invalid --on date %q (expected YYYY-MM-DD)", s.on)
+		}
+	}
+	for name, raw := range map[string]string{"endpoint": s.endpoint, "proxy": s.proxy} {
+		if raw == "" {
+			continue
+		}
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return usageError(fs, "invalid --%s %q (expected an http or https URL)", name, raw)
+		}
+	}
+	return nil
+}
+
+// labelFilter returns the --labels list or the --runners profile, or nil when every
+// label is kept.
+func (s sourceOptions) labelFilter() []string {
+	if s.runners {
+		return githubmeta.RunnerLabels()
+	}
+	var labels []string
+	for _, label := range strings.Split(s.labels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// families returns the address families selected by --only-family.
+func (s sourceOptions) families() githubmeta.Families {
+	switch s.onlyFamily {
+	case "ipv4":
+		return githubmeta.IPv4
+	case "ipv6":
+		return githubmeta.IPv6
+	}
+	return githubmeta.AllFamilies
+}
+
+// requestTimeout is the deadline of a single fetch: --read-timeout, capped by --timeout.
+func (s sourceOptions) requestTimeout() time.Duration {
+	if s.readTimeout > 0 && s.readTimeout < s.timeout {
+		return s.readTimeout
+	}
+	return s.timeout
+}
+
+// snapshot reports whether the ranges come from an archived file rather than the network.
+func (s sourceOptions) snapshot() bool {
+	return s.asOf != "" || s.archiveDir != "" || s.index != ""
+}
+
+// loadMeta reads the --as-of snapshot or --index file or fetches the live ranges,
+// reporting progress to info, and writes the --export-index file. The returned
+// disclaimer qualifies negative results.
+func (a *app) loadMeta(ctx context.Context, src sourceOptions, info io.Writer) (*githubmeta.MetaData, string, error) {
+	meta, disclaimer, err := a.readMeta(ctx, src, info)
+	if err != nil || src.exportIndex == "" {
+		return meta, disclaimer, err
+	}
+	if err := exportIndex(meta, src.exportIndex); err != nil {
+		return nil, "", fmt.Errorf("--export-index: %w", err)
+	}
+	fmt.Fprintf(info, "Wrote index to %s.\n", src.exportIndex)
+	return meta, disclaimer, nil
+}
+
+// readMeta is loadMeta without --export-index.
+func (a *app) readMeta(ctx context.Context, src sourceOptions, info io.Writer) (*githubmeta.MetaData, string, error) {
+	if src.index != "" {
+		fmt.Fprintf(info, "Loading index %s...\n", src.index)
+		meta, err := loadIndex(src.index)
+		if err != nil {
+			return nil, "", err
+		}
+		meta = filterSource(meta, src)
+		fmt.Fprintf(info, "Loaded %s from %s.\n", describeCount(meta), src.index)
+		return meta, "based on index " + src.index, nil
+	}
+	if src.archiveDir != "" {
+		on, _ := time.Parse(time.DateOnly, src.on)
+		path, err := githubmeta.FindSnapshot(src.archiveDir, on)
+		if err != nil {
+			return nil, "", err
+		}
+		src.asOf = path
+	}
+	if src.asOf != "" {
+		fmt.Fprintf(info, "Loading meta snapshot %s...\n", src.asOf)
+		meta, err := githubmeta.LoadFromFile(src.asOf)
+		if err != nil {
+			return nil, "", err
+		}
+		meta = filterSource(meta, src)
+		fmt.Fprintf(info, "Loaded %s from %s.\n", describeCount(meta), src.asOf)
+		return meta, "based on snapshot " + src.asOf, nil
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, src.requestTimeout())
+	defer cancel()
+
+	fmt.Fprintln(info, "Fetching GitHub IP ranges...")
+	meta, err := a.fetch(fetchCtx, src)
+	if err != nil {
+		return nil, "", err
+	}
+	fmt.Fprintf(info, "Loaded %s from GitHub.\n", describeCount(meta))
+	return meta, "based on current meta data", nil
+}
+
+// loadIndex reads a file written by exportIndex.
+func loadIndex(path string) (*githubmeta.MetaData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	meta, err := githubmeta.Decode(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return meta, nil
+}
+
+// exportIndex writes meta to path for --index.
+func exportIndex(meta *githubmeta.MetaData, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := meta.Encode(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// filterSource applies --only-family and --labels to meta read from a file.
+func filterSource(meta *githubmeta.MetaData, src sourceOptions) *githubmeta.MetaData {
+	if families := src.families(); families != githubmeta.AllFamilies {
+		meta = meta.FilterFamilies(families)
+	}
+	return filterLabels(meta, src)
+}
+
+// filterLabels applies --labels to meta.
+func filterLabels(meta *githubmeta.MetaData, src sourceOptions) *githubmeta.MetaData {
+	if labels := src.labelFilter(); len(labels) > 0 {
+		return meta.FilterLabels(labels...)
+	}
+	return meta
+}
+
+// describeCount summarises the size of meta for the startup banner.
+func describeCount(meta *githubmeta.MetaData) string {
+	total, v4, v6 := meta.PrefixCount()
+	return fmt.Sprintf("%d CIDR blocks (%d IPv4, %d IPv6)", total, v4, v6)
+}
+
+// fetch loads the meta data, honoring the cache, connection and --labels flags.
+func (a *app) fetch(ctx context.Context, src sourceOptions) (*githubmeta.MetaData, error) {
+	if src.cacheDirSet && src.cacheDir != "" && !src.cacheRO {
+		if err := os.MkdirAll(src.cacheDir, 0o755); err != nil {
+			return nil, fmt.Errorf("cache directory %s is not usable: %w", src.cacheDir, err)
+		}
+	}
+	meta, err := githubmeta.FetchWithOptions(ctx, a.fetchOptions(src))
+	if err != nil {
+		return nil, err
+	}
+	// FetchOptions.Families has already dropped the other family.
+	return filterLabels(meta, src), nil
+}
+
+// fetchOptions translates the source flags into githubmeta.FetchOptions.
+func (a *app) fetchOptions(src sourceOptions) githubmeta.FetchOptions {
+	fetchOpts := githubmeta.FetchOptions{
+		Client:             a.client,
+		CacheDir:           src.cacheDir,
+		UseDefaultCacheDir: !src.cacheDirSet,
+		ReadOnlyCache:      src.cacheRO,
+		Warnf:              a.warnf,
+		ExpectSHA256:       src.expectSHA256,
+		EmbeddedFallback:   true,
+		StrictEntries:      true,
+		ConnectTimeout:     src.connectTimeout,
+		Endpoint:           src.endpoint,
+		Families:           src.families(),
+		PreferCache:        src.preferCache,
+		CacheTTL:           src.cacheTTL,
+	}
+	if src.proxy != "" {
+		// parsed has validated the URL.
+		fetchOpts.Proxy, _ = url.Parse(src.proxy)
+	}
+	if src.explainCache {
+		fetchOpts.Tracef = a.tracef
+	}
+	if src.token != "" {
+		// Requests made with these options can go elsewhere, such as an
+		// --audit-allowlist URL, so the token is only sent to the meta endpoint's host.
+		host := "api.github.com"
+		if u, err := url.Parse(src.endpoint); err == nil && src.endpoint != "" {
+			host = u.Host
+		}
+		fetchOpts.RequestFunc = func(req *http.Request) {
+			if req.URL.Host == host {
+				req.Header.Set("Authorization", "Bearer "+src.token)
+			}
+		}
+	}
+	return fetchOpts
+}
+
+// cacheDir resolves the effective cache directory for the cache subcommand.
+func cacheDir(src sourceOptions) (string, error) {
+	if !src.cacheDirSet {
+		dir, err := githubmeta.DefaultCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("locate cache directory: %w", err)
+		}
+		return dir, nil
+	}
+	if src.cacheDir == "" {
+		return "", errors.New("caching is disabled (--cache-dir is empty)")
+	}
+	return src.cacheDir, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// maxCheckpointEvery caps how many addresses --state evaluates between checkpoints.
+const maxCheckpointEvery = 1 << 16
+
+// checkpointInterval is how many addresses --state evaluates between checkpoints:
+// a sixteenth of --limit, so any run within the limit saves progress along the
+// way, but never fewer than one address or more than maxCheckpointEvery.
+func checkpointInterval(limit int) int {
+	return max(1, min(limit/16, maxCheckpointEvery))
+}
+
+// errInterrupted reports a --state evaluation stopped by cancellation after saving
+// its progress.
+var errInterrupted = errors.New("evaluation interrupted")
+
+// cidrState is the --state checkpoint of a partially evaluated CIDR.
+type cidrState struct {
+	Prefix string `json:"prefix"`
+	// Next is the first address not yet evaluated.
+	Next      string         `json:"next"`
+	Owned     int            `json:"owned"`
+	LabelSets map[string]int `json:"label_sets"`
+}
+
+// evaluateCIDRWithState implements --state: it evaluates prefix like evaluateCIDR but
+// resumes from, and periodically saves progress to, the state file at path. ctx is
+// checked before every address; once it is cancelled the progress is saved and
+// errInterrupted returned. On completion the file is removed.
+func (c *checker) evaluateCIDRWithState(ctx context.Context, raw, path string) error {
+	prefix, err := netip.ParsePrefix(raw)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %q: %w", raw, err)
+	}
+	prefix = prefix.Masked()
+	total := githubmeta.PrefixSize(prefix)
+	if total.Cmp(big.NewInt(int64(c.limit))) > 0 {
+		// Too large: report it exactly as a plain run would.
+		c.evaluateCIDR(raw, prefix)
+		return nil
+	}
+
+	state, err := loadState(path, prefix)
+	if err != nil {
+		return err
+	}
+	next, err := netip.ParseAddr(state.Next)
+	if err != nil || !prefix.Contains(next) {
+		return fmt.Errorf("state file %s has an invalid next address %q", path, state.Next)
+	}
+
+	every := checkpointInterval(c.limit)
+	n := 0
+	for addr := range githubmeta.AddrRange(next, githubmeta.LastAddr(prefix)) {
+		if ctx.Err() != nil {
+			state.Next = addr.String()
+			if err := saveState(path, state); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w at %s; progress saved to %s", errInterrupted, state.Next, path)
+		}
+		c.checked++
+		if labels := c.meta.Lookup(addr); len(labels) > 0 {
+			state.Owned++
+			state.LabelSets[strings.Join(labels, ", ")]++
+		}
+		if n++; n%every == 0 && addr != githubmeta.LastAddr(prefix) {
+			state.Next = addr.Next().String()
+			if err := saveState(path, state); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove state file: %w", err)
+	}
+
+	res := cidrResult{
+		Input:      raw,
+		Prefix:     prefix.String(),
+		Total:      total,
+		OwnedCount: state.Owned,
+		LabelSets:  state.LabelSets,
+	}
+	res.UnmatchedLabels = unmatchedLabels(c.meta.Labels(), res.LabelSets)
+	c.emitCIDR(res)
+	return nil
+}
+
+// loadState reads the checkpoint at path, or starts a fresh one for prefix when
+// there is none. A checkpoint for a different prefix is an error.
+func loadState(path string, prefix netip.Prefix) (cidrState, error) {
+	fresh := cidrState{Prefix: prefix.String(), Next: prefix.Addr().String(), LabelSets: map[string]int{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fresh, nil
+	}
+	if err != nil {
+		return cidrState{}, fmt.Errorf("read state file: %w", err)
+	}
+	var state cidrState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return cidrState{}, fmt.Errorf("parse state file %s: %w", path, err)
+	}
+	if state.Prefix != prefix.String() {
+		return cidrState{}, fmt.Errorf("state file %s is for %s, not %s; remove it to start over", path, state.Prefix, prefix)
+	}
+	if state.LabelSets == nil {
+		state.LabelSets = map[string]int{}
+	}
+	return state, nil
+}
+
+// saveState writes state to path atomically, so an interruption mid-write leaves
+// the previous checkpoint intact.
+func saveState(path string, state cidrState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cancelAfter is a context that reports cancellation once Err has been called n times.
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestCheckpointInterval(t *testing.T) {
+	for limit, want := range map[int]int{0: 1, 10: 1, defaultCIDRLimit: defaultCIDRLimit / 16, 1 << 24: maxCheckpointEvery} {
+		if got := checkpointInterval(limit); got != want {
+			t.Errorf("checkpointInterval(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestStateResumesInterruptedCIDR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// Cancellation is noticed at the next address, not the next checkpoint.
+	cancelled := &cancelAfter{Context: context.Background(), n: 100}
+	c, out := newTestChecker(t)
+	if err := c.evaluateCIDRWithState(cancelled, "192.30.252.0/23", path); !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected errInterrupted, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("interrupted run printed %q", out)
+	}
+	state, err := loadState(path, netip.MustParsePrefix("192.30.252.0/23"))
+	if err != nil || state.Next != "192.30.252.100" || state.Owned != 100 {
+		t.Fatalf("unexpected checkpoint %+v (%v)", state, err)
+	}
+
+	if err := c.evaluateCIDRWithState(context.Background(), "192.30.252.0/23", path); err != nil {
+		t.Fatal(err)
+	}
+	uninterrupted, want := newTestChecker(t)
+	uninterrupted.evaluateInput("192.30.252.0/23")
+	if out.String() != want.String() {
+		t.Fatalf("resumed output %q, want %q", out, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the state file to be removed, got %v", err)
+	}
+}
+
+func TestStateRejectsDifferentCIDR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := saveState(path, cidrState{Prefix: "140.82.112.0/20", Next: "140.82.112.1"}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := newTestChecker(t)
+	err := c.evaluateCIDRWithState(context.Background(), "192.30.252.0/23", path)
+	if err == nil || !strings.Contains(err.Error(), "is for 140.82.112.0/20, not 192.30.252.0/23") {
+		t.Fatalf("expected a mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/dav1dc-github/cidr-calculator-github/githubmeta"
+)
+
+// runStats implements the stats subcommand. Without flags it prints how many prefixes
+// each label publishes; --label-overlap, --label-diff, --complement, --supernets,
+// --perimeter and --histogram answer narrower questions, and --audit-allowlist checks
+// a firewall allowlist against the ranges.
+func (a *app) runStats(ctx context.Context, args []string) int {
+	var src sourceOptions
+	fs := newFlagSet("stats", a.stderr)
+	src.addFlags(fs)
+	labelOverlap := fs.Bool("label-overlap", false, "print how many addresses two labels share: --label-overlap LABEL_A LABEL_B")
+	labelDiff := fs.Bool("label-diff", false, "list the prefixes only one of two labels covers, and those both cover: --label-diff LABEL_A LABEL_B")
+	complement := fs.String("complement", "", "print the parts of CIDR not covered by any GitHub range")
+	supernets := fs.Int("supernets", 0, "list the IPv4 /N blocks (1-32) that contain GitHub ranges")
+	supernets6 := fs.Int("supernets6", 0, "list the IPv6 /N blocks (1-128) that contain GitHub ranges")
+	perimeter := fs.Bool("perimeter", false, "list the addresses immediately outside each contiguous GitHub range")
+	auditAllowlist := fs.String("audit-allowlist", "", "list the GitHub ranges not covered by the CIDRs in this file or http(s) URL; exits 1 when any are missing")
+	histogram := fs.Bool("histogram", false, "chart how many ranges GitHub publishes of each prefix length")
+	if err := fs.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if *labelOverlap && *labelDiff {
+		return usageExitCode(usageError(fs, "--label-overlap and --label-diff are mutually exclusive"))
+	}
+	twoLabels := *labelOverlap || *labelDiff
+	if twoLabels && *complement != "" {
+		return usageExitCode(usageError(fs, "--label-overlap and --label-diff cannot be combined with --complement"))
+	}
+	if (*supernets != 0 || *supernets6 != 0) && (twoLabels || *complement != "") {
+		return usageExitCode(usageError(fs, "--supernets and --supernets6 cannot be combined with --label-overlap, --label-diff or --complement"))
+	}
+	if *perimeter && (twoLabels || *complement != "" || *supernets != 0 || *supernets6 != 0) {
+		return usageExitCode(usageError(fs, "--perimeter cannot be combined with --label-overlap, --label-diff, --complement or --supernets"))
+	}
+	if *histogram && (twoLabels || *complement != "" || *supernets != 0 || *supernets6 != 0 || *perimeter) {
+		return usageExitCode(usageError(fs, "--histogram cannot be combined with --label-overlap, --label-diff, --complement, --supernets or --perimeter"))
+	}
+	if *auditAllowlist != "" && (twoLabels || *complement != "" || *supernets != 0 || *supernets6 != 0 || *perimeter || *histogram) {
+		return usageExitCode(usageError(fs, "--audit-allowlist cannot be combined with other stats modes"))
+	}
+	if *supernets < 0 || *supernets > 32 || *supernets6 < 0 || *supernets6 > 128 {
+		return usageExitCode(usageError(fs, "--supernets must be between 1 and 32 and --supernets6 between 1 and 128"))
+	}
+	if *labelOverlap && fs.NArg() != 2 {
+		return usageExitCode(usageError(fs, "--label-overlap expects exactly two labels"))
+	}
+	if *labelDiff && fs.NArg() != 2 {
+		return usageExitCode(usageError(fs, "--label-diff expects exactly two labels"))
+	}
+	if !twoLabels && fs.NArg() > 0 {
+		return usageExitCode(usageError(fs, "unexpected arguments: %v", fs.Args()))
+	}
+	if err := src.parsed(fs); err != nil {
+		return usageExitCode(err)
+	}
+
+	meta, _, err := a.loadMeta(ctx, src, a.stdout)
+	if err != nil {
+		fmt.Fprintf(a.stderr, "error: %v\n", err)
+		return 1
+	}
+
+	switch {
+	case *labelOverlap:
+		printLabelOverlap(a.stdout, meta, fs.Arg(0), fs.Arg(1))
+	case *labelDiff:
+		printLabelDiff(a.stdout, meta, fs.Arg(0), fs.Arg(1))
+	case *complement != "":
+		if err := printComplement(a.stdout, meta, *complement); err != nil {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+			return 1
+		}
+	case *auditAllowlist != "":
+		return a.auditAllowlist(ctx, meta, src, *auditAllowlist)
+	case *histogram:
+		printHistogram(a.stdout, meta)
+	case *perimeter:
+		for _, addr := range meta.Perimeter() {
+			fmt.Fprintln(a.stdout, addr)
+		}
+	case *supernets != 0 || *supernets6 != 0:
+		if *supernets != 0 {
+			printPrefixes(a.stdout, meta.CoveredSupernets(*supernets))
+		}
+		if *supernets6 != 0 {
+			printPrefixes(a.stdout, meta.CoveredSupernets6(*supernets6))
+		}
+	default:
+		printLabelSummary(a.stdout, meta)
+	}
+	return 0
+}
+
+// printLabelSummary prints the number of published prefixes per label.
+func printLabelSummary(w io.Writer, meta *githubmeta.MetaData) {
+	counts := make(map[string]int)
+	for _, entry := range meta.Entries() {
+		counts[entry.Label]++
+	}
+	for _, label := range meta.Labels() {
+		fmt.Fprintf(w, "%s: %d prefixes\n", label, counts[label])
+	}
+}
+
+// printLabelOverlap implements --label-overlap.
+func printLabelOverlap(w io.Writer, meta *githubmeta.MetaData, a, b string) {
+	if label, ok := meta.ResolveLabel(a); ok {
+		a = label
+	}
+	if label, ok := meta.ResolveLabel(b); ok {
+		b = label
+	}
+	fmt.Fprintf(w, "%s and %s share %s addresses\n", a, b, meta.LabelOverlap(a, b))
+}
+
+// printLabelDiff implements --label-diff: it prints the prefixes only a covers, those
+// only b covers and those both cover, each under a heading.
+func printLabelDiff(w io.Writer, meta *githubmeta.MetaData, a, b string) {
+	if label, ok := meta.ResolveLabel(a); ok {
+		a = label
+	}
+	if label, ok := meta.ResolveLabel(b); ok {
+		b = label
+	}
+	onlyA, onlyB, both := meta.LabelPrefixDiff(a, b)
+	for _, section := range []struct {
+		heading  string
+		prefixes []netip.Prefix
+	}{
+		{"only in " + a, onlyA},
+		{"only in " + b, onlyB},
+		{"in both", both},
+	} {
+		fmt.Fprintf(w, "%s:\n", section.heading)
+		if len(section.prefixes) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, p := range section.prefixes {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+}
+
+// histogramWidth is the length of the longest --histogram bar.
+const histogramWidth = 40
+
+// printHistogram implements --histogram: a bar per prefix length of each family,
+// scaled so the most common length fills histogramWidth.
+func printHistogram(w io.Writer, meta *githubmeta.MetaData) {
+	v4, v6 := meta.PrefixLengthHistogram()
+	for _, family := range []struct {
+		name   string
+		counts map[int]int
+	}{
+		{"IPv4", v4},
+		{"IPv6", v6},
+	} {
+		if len(family.counts) == 0 {
+			continue
+		}
+		most := 0
+		for _, n := range family.counts {
+			most = max(most, n)
+		}
+		fmt.Fprintf(w, "%s prefix lengths:\n", family.name)
+		for _, bits := range slices.Sorted(maps.Keys(family.counts)) {
+			n := family.counts[bits]
+			// Every length present gets at least one mark, however rare.
+			bar := max(1, n*histogramWidth/most)
+			fmt.Fprintf(w, "  /%-3d %5d %s\n", bits, n, strings.Repeat("#", bar))
+		}
+	}
+}
+
+// printPrefixes prints one prefix per line.
+func printPrefixes(w io.Writer, prefixes []netip.Prefix) {
+	for _, p := range prefixes {
+		fmt.Fprintln(w, p)
+	}
+}
+
+// printComplement implements --complement.
+func printComplement(w io.Writer, meta *githubmeta.MetaData, raw string) error {
+	prefix, err := netip.ParsePrefix(raw)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %q: %w", raw, err)
+	}
+	uncovered := meta.ComplementWithin(prefix)
+	if len(uncovered) == 0 {
+		fmt.Fprintf(w, "%s is fully covered by GitHub ranges\n", prefix.Masked())
+		return nil
+	}
+	printPrefixes(w, uncovered)
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs is the FuncMap available to --template.
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+}
+
+// parseTemplate compiles a --template string, or the contents of --template-file.
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("result").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// emitTemplate renders res (an addrResult or cidrResult) with the user's template,
+// ending the output with a newline if the template did not.
+func (c *checker) emitTemplate(res any) {
+	var buf bytes.Buffer
+	if err := c.tmpl.Execute(&buf, res); err != nil {
+		fmt.Fprintf(c.out, "template error: %v\n", err)
+		return
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	_, _ = c.out.Write(buf.Bytes())
+}
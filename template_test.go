@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunTemplateRendersEachResult(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	a, stdout, stderr := newTestApp("")
+	tmpl := `{{.Input}} {{if .Owned}}GH{{else}}-{{end}} {{join .Labels ","}}`
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--template", tmpl, "192.30.252.44", "8.8.8.8"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if got, want := stdout.String(), "192.30.252.44 GH api,hooks\n8.8.8.8 - \n"; got != want {
+		t.Fatalf("template output = %q, want %q", got, want)
+	}
+}
+
+func TestRunTemplateFileUsesLower(t *testing.T) {
+	snapshot := writeTestFile(t, "meta.json", testMeta)
+	tmplFile := writeTestFile(t, "result.tmpl", "{{lower .Input}}\n")
+	a, stdout, stderr := newTestApp("")
+
+	if code := a.run(context.Background(), []string{"--as-of", snapshot, "--template-file", tmplFile, "2001:DB8:1::1"}); code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if got := stdout.String(); got != "2001:db8:1::1\n" {
+		t.Fatalf("template output = %q", got)
+	}
+}
+
+func TestRunTemplateParseErrorStopsBeforeEvaluation(t *testing.T) {
+	a, stdout, stderr := newTestApp("")
+	if code := a.run(context.Background(), []string{"--template", "{{.Input", "192.30.252.44"}); code != 2 {
+		t.Fatalf("expected exit 2, got %d", code)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no evaluation output, got %q", stdout)
+	}
+	if stderr.Len() == 0 {
+		t.Fatalf("expected a template parse error on stderr")
+	}
+}